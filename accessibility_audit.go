@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	RULE_A11Y_IMG_ALT      = "a11y-img-alt"
+	RULE_A11Y_EMPTY_LINK   = "a11y-empty-link"
+	RULE_A11Y_AMBIGUOUS    = "a11y-ambiguous-link-text"
+	RULE_A11Y_HTML_LANG    = "a11y-html-lang"
+	RULE_A11Y_INPUT_LABEL  = "a11y-input-label"
+	RULE_A11Y_IFRAME_TITLE = "a11y-iframe-title"
+
+	a11y_snippet_max_length = 60
+)
+
+func hasAccessibleName(s *goquery.Selection) bool {
+	for _, attr := range []string{"aria-label", "aria-labelledby", "title"} {
+		if value, ok := s.Attr(attr); ok && strings.TrimSpace(value) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func linkText(a *goquery.Selection) string {
+	text := strings.Join(strings.Fields(a.Text()), " ")
+	if text != "" {
+		return text
+	}
+	a.Find("img[alt]").EachWithBreak(func(i int, img *goquery.Selection) bool {
+		alt, _ := img.Attr("alt")
+		text = strings.TrimSpace(alt)
+		return text == ""
+	})
+	return text
+}
+
+func elementSnippet(s *goquery.Selection) string {
+	html, err := goquery.OuterHtml(s)
+	if err != nil {
+		return goquery.NodeName(s)
+	}
+	return shortenText(strings.Join(strings.Fields(html), " "), a11y_snippet_max_length)
+}
+
+func AuditAccessibility(doc *goquery.Document, pageUrl string, base nurl.URL) []Finding {
+	findings := make([]Finding, 0)
+	add := func(rule string, severity int, message string) {
+		findings = append(findings, Finding{rule, severity, pageUrl, message})
+	}
+
+	if lang, ok := doc.Find("html").First().Attr("lang"); !ok || strings.TrimSpace(lang) == "" {
+		add(RULE_A11Y_HTML_LANG, SEVERITY_ERROR, "<html> has no lang attribute")
+	}
+
+	doc.Find("img").Each(func(i int, img *goquery.Selection) {
+		if _, ok := img.Attr("alt"); !ok && !hasAccessibleName(img) {
+			add(RULE_A11Y_IMG_ALT, SEVERITY_ERROR, fmt.Sprintf("image without alt: %s", elementSnippet(img)))
+		}
+	})
+
+	targetsByText := make(map[string]map[string]bool)
+	doc.Find("a[href]").Each(func(i int, a *goquery.Selection) {
+		text := linkText(a)
+		if text == "" && !hasAccessibleName(a) {
+			add(RULE_A11Y_EMPTY_LINK, SEVERITY_ERROR, fmt.Sprintf("link without text: %s", elementSnippet(a)))
+			return
+		}
+		href, _ := a.Attr("href")
+		target, err := base.Parse(href)
+		if err != nil || text == "" {
+			return
+		}
+		target.Fragment = ""
+		key := strings.ToLower(text)
+		if targetsByText[key] == nil {
+			targetsByText[key] = make(map[string]bool)
+		}
+		targetsByText[key][target.String()] = true
+	})
+	for text, targets := range targetsByText {
+		if len(targets) > 1 {
+			add(RULE_A11Y_AMBIGUOUS, SEVERITY_WARNING, fmt.Sprintf("link text %q leads to %d different targets", text, len(targets)))
+		}
+	}
+
+	labelled := make(map[string]bool)
+	doc.Find("label[for]").Each(func(i int, label *goquery.Selection) {
+		id, _ := label.Attr("for")
+		labelled[id] = true
+	})
+	doc.Find("input, select, textarea").Each(func(i int, input *goquery.Selection) {
+		inputType, _ := input.Attr("type")
+		switch strings.ToLower(inputType) {
+		case "hidden", "submit", "button", "reset", "image":
+			return
+		}
+		id, _ := input.Attr("id")
+		if (id != "" && labelled[id]) || input.ParentsFiltered("label").Length() > 0 || hasAccessibleName(input) {
+			return
+		}
+		add(RULE_A11Y_INPUT_LABEL, SEVERITY_ERROR, fmt.Sprintf("form field without label: %s", elementSnippet(input)))
+	})
+
+	doc.Find("iframe").Each(func(i int, iframe *goquery.Selection) {
+		if title, ok := iframe.Attr("title"); (!ok || strings.TrimSpace(title) == "") && !hasAccessibleName(iframe) {
+			add(RULE_A11Y_IFRAME_TITLE, SEVERITY_WARNING, fmt.Sprintf("iframe without title: %s", elementSnippet(iframe)))
+		}
+	})
+	sortFindings(findings)
+	return findings
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	RULE_CACHE_MISSING   = "cache-missing"
+	RULE_CACHE_NO_STORE  = "cache-no-store"
+	RULE_CACHE_SHORT     = "cache-short-lifetime"
+	RULE_CACHE_VALIDATOR = "cache-no-validator"
+	RULE_COMPRESSION     = "compression-missing"
+
+	// Without br, measured GET bodies can be decoded with the standard library.
+	accept_encoding        = "gzip, deflate"
+	cache_min_max_age      = 7 * 24 * 60 * 60
+	compression_min_length = 1024
+)
+
+type CacheInfo struct {
+	CacheControl    string
+	Expires         string
+	ETag            string
+	LastModified    string
+	ContentEncoding string
+}
+
+func cacheInfoOf(resp *http.Response) CacheInfo {
+	header := resp.Header
+	info := CacheInfo{
+		CacheControl:    header.Get("Cache-Control"),
+		Expires:         header.Get("Expires"),
+		ETag:            header.Get("ETag"),
+		LastModified:    header.Get("Last-Modified"),
+		ContentEncoding: header.Get("Content-Encoding"),
+	}
+	if resp.Uncompressed {
+		info.ContentEncoding = "gzip"
+	}
+	return info
+}
+
+func (ci CacheInfo) directive(name string) (string, bool) {
+	for _, directive := range strings.Split(ci.CacheControl, ",") {
+		parts := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if strings.EqualFold(parts[0], name) {
+			if len(parts) == 2 {
+				return strings.Trim(parts[1], `"`), true
+			}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+func (ci CacheInfo) MaxAge() (int, bool) {
+	value, ok := ci.directive("max-age")
+	if !ok {
+		return 0, false
+	}
+	maxAge, err := strconv.Atoi(value)
+	return maxAge, err == nil
+}
+
+func (ci CacheInfo) String() string {
+	parts := make([]string, 0)
+	if ci.CacheControl != "" {
+		parts = append(parts, "Cache-Control: "+ci.CacheControl)
+	} else if ci.Expires != "" {
+		parts = append(parts, "Expires: "+ci.Expires)
+	}
+	if ci.ETag != "" {
+		parts = append(parts, "ETag: "+ci.ETag)
+	} else if ci.LastModified != "" {
+		parts = append(parts, "Last-Modified: "+ci.LastModified)
+	}
+	if ci.ContentEncoding != "" {
+		parts = append(parts, "encoding: "+ci.ContentEncoding)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.Contains(mediaType, "javascript"), strings.Contains(mediaType, "json"), strings.Contains(mediaType, "xml"):
+		return true
+	case mediaType == "font/ttf" || mediaType == "font/otf" || mediaType == "application/vnd.ms-fontobject":
+		return true
+	}
+	return false
+}
+
+func CacheFindings(us UrlStruct) []Finding {
+	findings := make([]Finding, 0)
+	if us.Intent == INTENT_HREF || us.Intent == INTENT_META || us.Status != STATUS_SUCCESS {
+		return findings
+	}
+	add := func(rule string, severity int, message string) {
+		findings = append(findings, Finding{rule, severity, us.Url, message})
+	}
+	ci := us.Cache
+	_, noStore := ci.directive("no-store")
+	_, noCache := ci.directive("no-cache")
+	_, immutable := ci.directive("immutable")
+	maxAge, hasMaxAge := ci.MaxAge()
+	switch {
+	case ci.CacheControl == "" && ci.Expires == "":
+		add(RULE_CACHE_MISSING, SEVERITY_WARNING, "asset has neither Cache-Control nor Expires")
+	case noStore:
+		add(RULE_CACHE_NO_STORE, SEVERITY_WARNING, fmt.Sprintf("asset is not cacheable: %q", ci.CacheControl))
+	case hasMaxAge && maxAge < cache_min_max_age && !immutable:
+		add(RULE_CACHE_SHORT, SEVERITY_INFO, fmt.Sprintf("asset max-age is %d s, expected at least %d s", maxAge, cache_min_max_age))
+	}
+	if !noStore && (noCache || !hasMaxAge) && ci.ETag == "" && ci.LastModified == "" {
+		add(RULE_CACHE_VALIDATOR, SEVERITY_INFO, "asset has neither ETag nor Last-Modified for revalidation")
+	}
+	if ci.ContentEncoding == "" && isCompressible(us.ContentType) && us.SourceSize >= compression_min_length {
+		add(RULE_COMPRESSION, SEVERITY_WARNING, fmt.Sprintf("%s of %s is served uncompressed", formatSize(us.SourceSize), us.ContentType))
+	}
+	return findings
+}
+
+func markCachingFindings(urlContainer *UrlTreeStruct) {
+	urlContainer.innerMutex.Lock()
+	defer urlContainer.innerMutex.Unlock()
+
+	checked := make(map[string]bool)
+	for _, us := range urlContainer.InnerUrls {
+		if checked[us.Url] {
+			continue
+		}
+		checked[us.Url] = true
+		for _, f := range CacheFindings(us) {
+			urlContainer.AppendFinding(f)
+		}
+	}
+}
+
+func CachingHostSummary(root *UrlTreeStruct) []hostRuleCount {
+	return summariseByHost(collectFindings(root, "cache-", "compression-"))
+}
@@ -1,51 +1,102 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	nurl "net/url"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/sync/errgroup"
 )
 
-var (
-	errTimeOut = fmt.Errorf("head request time out")
-)
-
 const (
 	SCHEME_MAILTO = "mailto"
 	SCHEME_TEL    = "tel"
 	SCHEME_CALLTO = "callto"
 )
 
-func headRequest(client *http.Client, url string) (*http.Response, error) {
-	resp, err := client.Head(url)
+func headRequest(client *http.Client, url string, timing *RequestTiming) (*http.Response, int, error) {
+	resp, err := doRequest(client, http.MethodHead, url, true, timing)
 	if err != nil {
-		if os.IsTimeout(err) {
-			return nil, errTimeOut
-		} else {
-			fmt.Println(err)
-			return nil, err
-		}
+		return nil, classifyError(err), err
+	}
+	return resp, FAILURE_NONE, nil
+}
+
+func getRequest(client *http.Client, url string, timing *RequestTiming) (*http.Response, int, error) {
+	resp, err := doRequest(client, http.MethodGet, url, false, timing)
+	if err != nil {
+		return nil, classifyError(err), err
 	}
-	return resp, nil
+	return resp, FAILURE_NONE, nil
 }
 
-func getRequest(client *http.Client, url string) (*http.Response, error) {
-	resp, err := client.Get(url)
+// measuredGetRequest asks for the same encodings as headRequest, so the size
+// of the returned body is counted in transferred bytes like the Content-Length
+// of a HEAD response. Read the decoded content from the returned body.
+func measuredGetRequest(client *http.Client, url string, timing *RequestTiming) (*http.Response, *wireBody, int, error) {
+	resp, err := doRequest(client, http.MethodGet, url, true, timing)
 	if err != nil {
-		if os.IsTimeout(err) {
-			return nil, errTimeOut
-		} else {
-			fmt.Println(err)
-			return nil, err
+		return nil, nil, classifyError(err), err
+	}
+	body, err := newWireBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, classifyError(err), err
+	}
+	resp.Body = body
+	return resp, body, FAILURE_NONE, nil
+}
+
+func directStatusProblem(url string) string {
+	client := newHttpClient(time.Second*20, noRedirects)
+	resp, _, err := headRequest(client, url, nil)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		resp, _, err = getRequest(client, url, nil)
+	}
+	if err != nil {
+		return err.Error()
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		return fmt.Sprintf("redirects (%d) to %s", resp.StatusCode, resp.Header.Get("Location"))
+	case resp.StatusCode != 200:
+		return fmt.Sprintf("returns %d", resp.StatusCode)
+	}
+	return ""
+}
+
+func probeDirectStatus(urls []string) map[string]string {
+	problems := make(map[string]string)
+	var problemsMtx sync.Mutex
+	group := new(errgroup.Group)
+	group.SetLimit(max_inner_pool)
+	probed := make(map[string]bool)
+	for _, url := range urls {
+		if probed[url] {
+			continue
 		}
+		probed[url] = true
+		target := url
+		group.Go(func() error {
+			if problem := directStatusProblem(target); problem != "" {
+				problemsMtx.Lock()
+				problems[target] = problem
+				problemsMtx.Unlock()
+			}
+			return nil
+		})
 	}
-	return resp, nil
+	group.Wait()
+	return problems
 }
 
 func checkUrl(base nurl.URL, url string, urlTree *UrlTreeStruct, progress func(string, float64), index, count float64) {
@@ -54,47 +105,63 @@ func checkUrl(base nurl.URL, url string, urlTree *UrlTreeStruct, progress func(s
 		//fmt.Println("Uts not found!")
 		return
 	}
-	client := &http.Client{
-		Timeout: time.Second * 10,
-		CheckRedirect: func(r *http.Request, via []*http.Request) error {
-			r.URL.Opaque = r.URL.Path
-			return nil
-		},
-	}
-	resp, err := getRequest(client, url)
+	uts.ResetAudit()
+	client := newHttpClient(time.Second*10, keepOpaquePath)
+	resp, wire, failure, err := measuredGetRequest(client, url, &uts.Timing)
+	uts.SetFailure(failure, err)
 	if err != nil {
-		switch err {
-		case errTimeOut:
-			uts.Status = STATUS_LONGWAIT
-			fmt.Println("Too long to wait")
-			return
-		default:
-			uts.Status = STATUS_FAILURE
-			return
-		}
+		uts.Status = failureStatus(failure)
+		return
 	}
+	defer resp.Body.Close()
+	tlsAudit.Record(resp)
+	uts.TlsUnverified = isTlsUnverified(resp)
 	statCode := resp.StatusCode
 	//fmt.Println("Code of", url, "is", statCode)
 	if statCode == 200 {
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
+		raw, err := io.ReadAll(wire)
+		var doc *goquery.Document
+		if err == nil {
+			doc, err = goquery.NewDocumentFromReader(bytes.NewReader(raw))
+		}
+		uts.Timing.Finish()
+		uts.PageSize = wire.Size()
+		uts.LastModified = resp.Header.Get("Last-Modified")
 		if err != nil {
 			uts.Status = STATUS_PROBLEM
-			fmt.Println("Can not read body")
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				uts.SetFailure(FAILURE_TRUNCATED, err)
+			} else {
+				uts.SetFailure(classifyError(err), err)
+			}
 			return
 		}
 		//fmt.Println("Size of", url, "document is", doc.Length())
+		source := NewPageSource(raw, doc)
 		imgs := doc.Find("img")
 		as := doc.Find("a")
 		asCount := float64(as.Length())
 		partCoeff := 1 / asCount / count
 		group := new(errgroup.Group)
 		group.SetLimit(max_inner_pool)
-		uts.InnerUrls = make([]UrlStruct, 0)
+		seo, seoFindings := AuditSeo(doc, resp.Header, url, base)
+		uts.Seo = seo
+		uts.Content = NewContentSignature(doc)
+		uts.Findings = append(uts.Findings, seoFindings...)
+		structuredTypes, structuredFindings := AuditStructuredData(doc, url)
+		uts.Seo.StructuredTypes = structuredTypes
+		uts.Findings = append(uts.Findings, structuredFindings...)
+		uts.Findings = append(uts.Findings, AuditSocialTags(doc, url)...)
+		uts.Findings = append(uts.Findings, AuditSecurityHeaders(resp, url)...)
+		if currentSettings().AccessibilityChecks {
+			uts.Findings = append(uts.Findings, AuditAccessibility(doc, url, base)...)
+		}
 		imgs.Each(func(i int, a *goquery.Selection) {
 			if href, ok := a.Attr("src"); ok {
 				if err == nil {
+					context := source.Context(a, "src")
 					group.Go(func() error {
-						checkInnerUrl(base, href, uts, INTENT_SRC)
+						checkInnerUrl(base, href, uts, INTENT_SRC, context)
 						progress(fmt.Sprintf("Checked image %s", href), (partCoeff*float64(i)+index)/count)
 						return nil
 					})
@@ -103,11 +170,30 @@ func checkUrl(base nurl.URL, url string, urlTree *UrlTreeStruct, progress func(s
 				}
 			}
 		})
+		doc.Find("script[src]").Each(func(i int, script *goquery.Selection) {
+			src, _ := script.Attr("src")
+			context := source.Context(script, "src")
+			group.Go(func() error {
+				checkInnerUrl(base, src, uts, INTENT_SRC, context)
+				progress(fmt.Sprintf("Checked script %s", src), (partCoeff*float64(i)+index)/count)
+				return nil
+			})
+		})
+		doc.Find("video[src], video source[src]").Each(func(i int, video *goquery.Selection) {
+			src, _ := video.Attr("src")
+			context := source.Context(video, "src")
+			group.Go(func() error {
+				checkInnerUrl(base, src, uts, INTENT_SRC, context)
+				progress(fmt.Sprintf("Checked video %s", src), (partCoeff*float64(i)+index)/count)
+				return nil
+			})
+		})
 		as.Each(func(i int, a *goquery.Selection) {
 			if href, ok := a.Attr("href"); ok {
 				if err == nil {
+					context := source.Context(a, "href")
 					group.Go(func() error {
-						checkInnerUrl(base, href, uts, INTENT_HREF)
+						checkInnerUrl(base, href, uts, INTENT_HREF, context)
 						progress(fmt.Sprintf("Checked inner %s", href), (partCoeff*float64(i)+index)/count)
 						return nil
 					})
@@ -116,79 +202,122 @@ func checkUrl(base nurl.URL, url string, urlTree *UrlTreeStruct, progress func(s
 				}
 			}
 		})
+		if pageUrl, err := nurl.Parse(url); err == nil {
+			checkStyles(doc, source, *pageUrl, uts, group)
+		}
+		checkSocialImages(doc, source, base, uts, group)
+		if currentSettings().ImageSizeChecks {
+			checkImageSizes(doc, base, uts, group)
+		}
+		var mixed map[string]int
+		if resp.Request.URL.Scheme == "https" {
+			var unchecked map[string]mixedReference
+			mixed, unchecked = FindMixedContent(doc, base)
+			checkMixedResources(unchecked, source, base, uts, group)
+		}
 		group.Wait()
+		if mixed != nil {
+			markMixedContent(uts, mixed, currentSettings().ProbeMixedHttps)
+		}
+		markCachingFindings(uts)
+		markSocialImages(uts)
+		markContentTypeMismatch(uts, ExpectedResourceTypes(doc, base))
+		if soft, reason := DetectSoft404(*resp.Request.URL, doc, currentSettings()); soft {
+			uts.AppendFinding(Finding{RULE_SOFT404, SEVERITY_ERROR, url, reason})
+			uts.Status = STATUS_SOFT404
+			return
+		}
+		checkWeightBudget(uts, currentSettings())
 		uts.Status = STATUS_SUCCESS
 		return
 	} else if statCode >= 301 && statCode <= 308 {
-		newUrl, err := resp.Location()
-		if err != nil {
+		uts.Status = statCode
+		if newUrl, err := resp.Location(); err == nil && newUrl.String() != url {
 			checkUrl(base, newUrl.String(), urlTree, progress, index, count)
-			return
 		}
+		return
 	}
-	fmt.Println("Stat code", statCode)
 	uts.Status = statCode
 }
 
-func configureAndBindInnerUrl(url string, status, linkType, intent int, sourceSize int64, urlContainer *UrlTreeStruct) {
+func configureInnerUrl(url string, status, linkType, intent int, sourceSize int64) *UrlStruct {
 	urlElement := NewUrlStruct(url)
 	urlElement.Status = status
 	urlElement.LinkType = linkType
 	urlElement.SourceSize = sourceSize
 	urlElement.Intent = intent
+	return urlElement
+}
+
+func configureAndBindInnerUrl(url string, status, linkType, intent int, sourceSize int64, context LinkContext, urlContainer *UrlTreeStruct) {
+	urlElement := configureInnerUrl(url, status, linkType, intent, sourceSize)
+	urlElement.Context = context
 	urlContainer.AppendInnerUrl(urlElement)
 }
 
-func checkInnerUrl(base nurl.URL, url string, urlContainer *UrlTreeStruct, intent int) {
+func bindFailedInnerUrl(url string, failure, intent int, err error, timing RequestTiming, context LinkContext, urlContainer *UrlTreeStruct) {
+	urlElement := configureInnerUrl(url, failureStatus(failure), LINK_TYPE_PAGE, intent, -1)
+	urlElement.Context = context
+	urlElement.Timing = timing
+	urlElement.Failure = failure
+	urlElement.FailureMessage = err.Error()
+	urlContainer.AppendInnerUrl(urlElement)
+}
+
+func checkInnerUrl(base nurl.URL, url string, urlContainer *UrlTreeStruct, intent int, context LinkContext) {
 	based_url, err := base.Parse(url)
 	if err != nil {
-		fmt.Println("try base failed:", url)
-		configureAndBindInnerUrl(url, STATUS_FAILURE, LINK_TYPE_PAGE, intent, -1, urlContainer)
+		bindFailedInnerUrl(url, FAILURE_INVALID_URL, intent, err, RequestTiming{}, context, urlContainer)
 		return
 	}
 	str_based_url := based_url.String()
 	//fmt.Println("Sceme of", url, "is", part_url.Scheme)
 	switch based_url.Scheme {
 	case SCHEME_MAILTO:
-		configureAndBindInnerUrl(str_based_url, STATUS_PROBLEM, LINK_TYPE_MAILTO, intent, -1, urlContainer)
+		configureAndBindInnerUrl(str_based_url, STATUS_PROBLEM, LINK_TYPE_MAILTO, intent, -1, context, urlContainer)
 		return
 	case SCHEME_TEL:
-		configureAndBindInnerUrl(str_based_url, STATUS_PROBLEM, LINK_TYPE_TEL, intent, -1, urlContainer)
+		configureAndBindInnerUrl(str_based_url, STATUS_PROBLEM, LINK_TYPE_TEL, intent, -1, context, urlContainer)
 		return
 	case SCHEME_CALLTO:
-		configureAndBindInnerUrl(str_based_url, STATUS_PROBLEM, LINK_TYPE_CALLTO, intent, -1, urlContainer)
+		configureAndBindInnerUrl(str_based_url, STATUS_PROBLEM, LINK_TYPE_CALLTO, intent, -1, context, urlContainer)
 		return
 	}
-	client := &http.Client{
-		Timeout: time.Second * 20,
-	}
-	resp, err := headRequest(client, str_based_url)
+	client := newHttpClient(time.Second*20, limitRedirects)
+	var timing RequestTiming
+	resp, failure, err := headRequest(client, str_based_url, &timing)
 	if err != nil {
-		switch err {
-		case errTimeOut:
-			fmt.Println("To long to wait")
-			configureAndBindInnerUrl(str_based_url, STATUS_LONGWAIT, LINK_TYPE_PAGE, intent, -1, urlContainer)
-			return
-		default:
-			configureAndBindInnerUrl(str_based_url, STATUS_FAILURE, LINK_TYPE_PAGE, intent, -1, urlContainer)
-			return
-		}
+		bindFailedInnerUrl(str_based_url, failure, intent, err, timing, context, urlContainer)
+		return
 	}
+	resp.Body.Close()
+	tlsAudit.Record(resp)
 	statCode := resp.StatusCode
 	contentLen := resp.ContentLength
 	//fmt.Println("Code of inner", url, "is", statCode)
 	if statCode == 200 {
-		configureAndBindInnerUrl(str_based_url, STATUS_SUCCESS, LINK_TYPE_PAGE, intent, contentLen, urlContainer)
+		urlElement := configureInnerUrl(str_based_url, STATUS_SUCCESS, LINK_TYPE_PAGE, intent, contentLen)
+		urlElement.Context = context
+		urlElement.TlsUnverified = isTlsUnverified(resp)
+		urlElement.Timing = timing
+		fillResourceInfo(urlElement, resp)
+		if contentLen < 0 && countsTowardWeight(intent) {
+			urlElement.SourceSize = measureSize(str_based_url, urlElement.ResourceType)
+		}
+		urlContainer.AppendInnerUrl(urlElement)
 		return
 	} else if statCode >= 300 && statCode <= 308 {
-		newUrl, err := resp.Location()
-		if err != nil {
-			checkInnerUrl(base, newUrl.String(), urlTree, intent)
+		if newUrl, err := resp.Location(); err == nil && newUrl.String() != str_based_url {
+			checkInnerUrl(base, newUrl.String(), urlContainer, intent, context)
 			return
 		}
 	}
-	fmt.Println("Stat code", statCode)
-	configureAndBindInnerUrl(str_based_url, statCode, LINK_TYPE_PAGE, intent, contentLen, urlContainer)
+	urlElement := configureInnerUrl(str_based_url, statCode, LINK_TYPE_PAGE, intent, contentLen)
+	urlElement.Context = context
+	urlElement.TlsUnverified = isTlsUnverified(resp)
+	urlElement.Timing = timing
+	fillResourceInfo(urlElement, resp)
+	urlContainer.AppendInnerUrl(urlElement)
 }
 
 //fmt.Println("Status:", resp.StatusCode)
@@ -221,6 +350,10 @@ func InitCheckUrls(searchedUrl string, listOfUrls *[]string, urlTree *UrlTreeStr
 		})
 	}
 	group.Wait()
+	applySiteFindings(urlTree, seoSiteRules, SeoSiteFindings(urlTree))
+	applySiteFindings(urlTree, hreflangSiteRules, HreflangSiteFindings(urlTree))
+	applySiteFindings(urlTree, duplicateSiteRules, DuplicateSiteFindings(urlTree))
+	applySiteFindings(urlTree, linkSiteRules, LinkSiteFindings(urlTree, currentSettings().MaxClickDepth))
 }
 
 func InitCheckUrl(searchedUrl string, selectedUrl *UrlTreeStruct, progress func(string, float64)) {
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"testing"
+)
+
+func TestCheckInnerUrlRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/choices", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/target")
+		w.WriteHeader(http.StatusMultipleChoices)
+	})
+	mux.HandleFunc("/self", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/self")
+		w.WriteHeader(http.StatusMultipleChoices)
+	})
+	mux.HandleFunc("/nowhere", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusFound)
+	})
+	mux.HandleFunc("/target", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	base, err := nurl.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path   string
+		url    string
+		status int
+	}{
+		{"/choices", server.URL + "/target", STATUS_SUCCESS},
+		{"/self", server.URL + "/self", http.StatusMultipleChoices},
+		{"/nowhere", server.URL + "/nowhere", http.StatusFound},
+	}
+	for _, c := range cases {
+		page := NewUrlTreeStruct(server.URL + "/")
+		checkInnerUrl(*base, c.path, page, INTENT_HREF, LinkContext{})
+		if len(page.InnerUrls) != 1 {
+			t.Fatalf("%s: inner URLs %v", c.path, page.InnerUrls)
+		}
+		if inner := page.InnerUrls[0]; inner.Url != c.url || inner.Status != c.status {
+			t.Errorf("%s: got %s with status %d, want %s with %d", c.path, inner.Url, inner.Status, c.url, c.status)
+		}
+	}
+}
+
+func TestCheckUrlChecksSuspectedTrap(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<html><head><title>Search</title></head><body><h1>Search</h1></body></html>"))
+	}))
+	defer server.Close()
+	base, err := nurl.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetSoft404Probes()
+	defer resetSoft404Probes()
+
+	page := NewUrlTreeStruct(server.URL + "/search/")
+	page.Trap = "linked with more than 20 different query strings"
+	checkUrl(*base, page.Url, page, func(string, float64) {}, 0, 1)
+	if page.Status != STATUS_SUCCESS {
+		t.Errorf("suspected trap not checked: status %d", page.Status)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const RULE_CONTENT_TYPE_MISMATCH = "content-type-mismatch"
+
+type typedElement struct {
+	selector string
+	attr     string
+	expected int
+}
+
+var typedElements = []typedElement{
+	{"img[src]", "src", RESOURCE_IMAGE},
+	{"script[src]", "src", RESOURCE_SCRIPT},
+	{"link[rel~=stylesheet][href]", "href", RESOURCE_CSS},
+	{"video[src]", "src", RESOURCE_MEDIA},
+	{"video source[src]", "src", RESOURCE_MEDIA},
+	{`meta[property="og:image"][content]`, "content", RESOURCE_IMAGE},
+	{`meta[name="twitter:image"][content]`, "content", RESOURCE_IMAGE},
+}
+
+func isExecutableScript(script *goquery.Selection) bool {
+	scriptType, ok := script.Attr("type")
+	if !ok {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSpace(scriptType)) {
+	case "", "module", "text/javascript", "application/javascript", "text/ecmascript", "application/ecmascript":
+		return true
+	}
+	return false
+}
+
+func ExpectedResourceTypes(doc *goquery.Document, base nurl.URL) map[string]int {
+	expected := make(map[string]int)
+	for _, element := range typedElements {
+		el := element
+		doc.Find(el.selector).Each(func(i int, s *goquery.Selection) {
+			if el.expected == RESOURCE_SCRIPT && !isExecutableScript(s) {
+				return
+			}
+			ref, _ := s.Attr(el.attr)
+			refUrl, err := base.Parse(ref)
+			if err != nil {
+				return
+			}
+			if _, ok := expected[refUrl.String()]; !ok {
+				expected[refUrl.String()] = el.expected
+			}
+		})
+	}
+	return expected
+}
+
+func markContentTypeMismatch(urlContainer *UrlTreeStruct, expected map[string]int) {
+	urlContainer.innerMutex.Lock()
+	defer urlContainer.innerMutex.Unlock()
+
+	checked := make(map[string]bool)
+	for _, us := range urlContainer.InnerUrls {
+		kind, ok := expected[us.Url]
+		if !ok || us.Intent == INTENT_HREF || us.Status != STATUS_SUCCESS || checked[us.Url] {
+			continue
+		}
+		checked[us.Url] = true
+		if us.ContentType == "" {
+			urlContainer.AppendFinding(Finding{RULE_CONTENT_TYPE_MISMATCH, SEVERITY_WARNING, us.Url,
+				fmt.Sprintf("%s is served without Content-Type", resourceTypeName(kind))})
+			continue
+		}
+		actual, _ := mediaResourceType(us.ContentType)
+		if actual == kind {
+			continue
+		}
+		severity := SEVERITY_WARNING
+		if actual == RESOURCE_HTML {
+			severity = SEVERITY_ERROR
+		}
+		urlContainer.AppendFinding(Finding{RULE_CONTENT_TYPE_MISMATCH, severity, us.Url,
+			fmt.Sprintf("expected %s, but Content-Type is %s", resourceTypeName(kind), us.ContentType)})
+	}
+}
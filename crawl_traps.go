@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	max_crawl_depth     = 12
+	max_segment_repeats = 2
+	max_url_length      = 1024
+	max_query_variants  = 50
+)
+
+var (
+	crawlTraps    = make(map[string]string)
+	queryVariants = make(map[string]map[string]bool)
+	crawlTrapsMtx sync.Mutex
+)
+
+func resetCrawlTraps() {
+	crawlTrapsMtx.Lock()
+	crawlTraps = make(map[string]string)
+	queryVariants = make(map[string]map[string]bool)
+	crawlTrapsMtx.Unlock()
+}
+
+func crawlTrapReason(url string) string {
+	if len(url) > max_url_length {
+		return fmt.Sprintf("URL is %d characters long", len(url))
+	}
+	parsed, err := nurl.Parse(url)
+	if err != nil {
+		return ""
+	}
+	segments := strings.FieldsFunc(parsed.Path, func(r rune) bool { return r == '/' })
+	if len(segments) > max_crawl_depth {
+		return fmt.Sprintf("path is %d levels deep", len(segments))
+	}
+	counts := make(map[string]int)
+	for _, segment := range segments {
+		counts[segment]++
+		if counts[segment] > max_segment_repeats {
+			return fmt.Sprintf("segment %q repeats %d times", segment, counts[segment])
+		}
+	}
+	for size := 1; size*2 <= len(segments); size++ {
+		for start := 0; start+size*2 <= len(segments); start++ {
+			if strings.Join(segments[start:start+size], "/") == strings.Join(segments[start+size:start+size*2], "/") {
+				return fmt.Sprintf("segments %q repeat in a row", strings.Join(segments[start:start+size], "/"))
+			}
+		}
+	}
+	return ""
+}
+
+func markCrawlTrap(url, reason string) {
+	crawlTrapsMtx.Lock()
+	defer crawlTrapsMtx.Unlock()
+	if _, ok := crawlTraps[url]; !ok {
+		crawlTraps[url] = reason
+	}
+}
+
+func isCrawlTrap(url string) bool {
+	crawlTrapsMtx.Lock()
+	defer crawlTrapsMtx.Unlock()
+	_, ok := crawlTraps[url]
+	return ok
+}
+
+// countQueryVariant remembers the query string a link to pageUrl was seen with
+// and marks the page as a trap once too many different variants show up.
+func countQueryVariant(pageUrl, query string) {
+	if query == "" {
+		return
+	}
+	crawlTrapsMtx.Lock()
+	variants, ok := queryVariants[pageUrl]
+	if !ok {
+		variants = make(map[string]bool)
+		queryVariants[pageUrl] = variants
+	}
+	variants[query] = true
+	count := len(variants)
+	crawlTrapsMtx.Unlock()
+	if count > max_query_variants {
+		markCrawlTrap(pageUrl, fmt.Sprintf("linked with more than %d different query strings", max_query_variants))
+	}
+}
+
+func applyCrawlTraps(root *UrlTreeStruct) {
+	crawlTrapsMtx.Lock()
+	defer crawlTrapsMtx.Unlock()
+	root.Walk(func(uts *UrlTreeStruct) {
+		uts.Trap = crawlTraps[uts.Url]
+	})
+}
+
+func trapMark(reason string) string {
+	if reason != "" {
+		return "[suspected trap] "
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io"
+	nurl "net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/sync/errgroup"
+)
+
+const max_stylesheet_size = 2 * 1024 * 1024
+
+var (
+	cssCommentRegexp = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssUrlRegexp     = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+	cssImportRegexp  = regexp.MustCompile(`(?i)@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+func firstGroup(match []string) string {
+	for _, group := range match[1:] {
+		if group != "" {
+			return group
+		}
+	}
+	return ""
+}
+
+func ExtractCssUrls(css string) []string {
+	css = cssCommentRegexp.ReplaceAllString(css, "")
+	found := make(map[string]bool)
+	result := make([]string, 0)
+	appendRef := func(ref string) {
+		ref = strings.TrimSpace(ref)
+		lower := strings.ToLower(ref)
+		if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(lower, "data:") || found[ref] {
+			return
+		}
+		found[ref] = true
+		result = append(result, ref)
+	}
+	for _, match := range cssImportRegexp.FindAllStringSubmatch(css, -1) {
+		appendRef(firstGroup(match))
+	}
+	for _, match := range cssUrlRegexp.FindAllStringSubmatch(css, -1) {
+		appendRef(firstGroup(match))
+	}
+	return result
+}
+
+func checkStylesheet(base nurl.URL, href string, context LinkContext, urlContainer *UrlTreeStruct) {
+	sheetUrl, err := base.Parse(href)
+	if err != nil {
+		bindFailedInnerUrl(href, FAILURE_INVALID_URL, INTENT_CSS, err, RequestTiming{}, context, urlContainer)
+		return
+	}
+	strSheetUrl := sheetUrl.String()
+	client := newHttpClient(time.Second*20, limitRedirects)
+	var timing RequestTiming
+	resp, wire, failure, err := measuredGetRequest(client, strSheetUrl, &timing)
+	if err != nil {
+		bindFailedInnerUrl(strSheetUrl, failure, INTENT_CSS, err, timing, context, urlContainer)
+		return
+	}
+	defer resp.Body.Close()
+	tlsAudit.Record(resp)
+
+	urlElement := configureInnerUrl(strSheetUrl, resp.StatusCode, LINK_TYPE_FILE, INTENT_CSS, resp.ContentLength)
+	urlElement.Context = context
+	urlElement.TlsUnverified = isTlsUnverified(resp)
+	urlElement.Timing = timing
+	urlElement.ContentType = resp.Header.Get("Content-Type")
+	urlElement.ResourceType = RESOURCE_CSS
+	urlElement.Cache = cacheInfoOf(resp)
+	if resp.StatusCode != 200 {
+		urlContainer.AppendInnerUrl(urlElement)
+		return
+	}
+	urlElement.Status = STATUS_SUCCESS
+	body, err := io.ReadAll(io.LimitReader(wire, max_stylesheet_size))
+	urlElement.Timing.Finish()
+	if err != nil {
+		urlElement.Failure = classifyError(err)
+		urlElement.FailureMessage = err.Error()
+		urlElement.Status = STATUS_PROBLEM
+		urlContainer.AppendInnerUrl(urlElement)
+		return
+	}
+	if urlElement.SourceSize == -1 {
+		urlElement.SourceSize = wire.Size()
+	}
+	urlContainer.AppendInnerUrl(urlElement)
+
+	// Resources of the stylesheet point back to the link element including it.
+	context.Attr = css_url_attr
+	for _, ref := range ExtractCssUrls(string(body)) {
+		checkInnerUrl(*resp.Request.URL, ref, urlContainer, INTENT_CSS, context)
+	}
+}
+
+func checkStyles(doc *goquery.Document, source *PageSource, pageUrl nurl.URL, urlContainer *UrlTreeStruct, group *errgroup.Group) {
+	doc.Find("link[rel]").Each(func(i int, link *goquery.Selection) {
+		rel, _ := link.Attr("rel")
+		href, ok := link.Attr("href")
+		if !ok || !strings.Contains(strings.ToLower(rel), "stylesheet") {
+			return
+		}
+		context := source.Context(link, "href")
+		group.Go(func() error {
+			checkStylesheet(pageUrl, href, context, urlContainer)
+			return nil
+		})
+	})
+
+	checkInline := func(css string, context LinkContext) {
+		for _, ref := range ExtractCssUrls(css) {
+			inlineRef := ref
+			group.Go(func() error {
+				checkInnerUrl(pageUrl, inlineRef, urlContainer, INTENT_CSS, context)
+				return nil
+			})
+		}
+	}
+	doc.Find("style").Each(func(i int, style *goquery.Selection) {
+		checkInline(style.Text(), source.Context(style, css_url_attr))
+	})
+	doc.Find("[style]").Each(func(i int, element *goquery.Selection) {
+		style, _ := element.Attr("style")
+		checkInline(style, source.Context(element, "style"))
+	})
+}
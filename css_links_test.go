@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestCheckStylesResolvesAgainstPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/blog/css/site.css" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte("body { color: red }"))
+	}))
+	defer server.Close()
+	pageUrl, err := nurl.Parse(server.URL + "/blog/post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := `<html><head><link rel="stylesheet" href="css/site.css"></head><body></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := NewUrlTreeStruct(pageUrl.String())
+	group := new(errgroup.Group)
+	checkStyles(doc, NewPageSource([]byte(raw), doc), *pageUrl, page, group)
+	group.Wait()
+	if len(page.InnerUrls) != 1 {
+		t.Fatalf("inner URLs: %v", page.InnerUrls)
+	}
+	if sheet := page.InnerUrls[0]; sheet.Url != server.URL+"/blog/css/site.css" || sheet.Status != STATUS_SUCCESS {
+		t.Errorf("stylesheet: got %s with status %d", sheet.Url, sheet.Status)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	DUPLICATE_NONE = iota
+	DUPLICATE_EXACT
+	DUPLICATE_NEAR
+)
+
+const (
+	RULE_DUPLICATE_CONTENT = "content-duplicate"
+	RULE_NEAR_DUPLICATE    = "content-near-duplicate"
+
+	duplicate_shingle   = 3
+	duplicate_min_words = 20
+	simhash_distance    = 3
+)
+
+type ContentSignature struct {
+	Hash    string
+	SimHash uint64
+	Words   int
+}
+
+type DuplicateGroup struct {
+	Kind int
+	Urls []string
+}
+
+func mainText(doc *goquery.Document) string {
+	main := doc.Find("main, [role=main], article").First()
+	if main.Length() == 0 {
+		main = doc.Find("body")
+	}
+	main = main.Clone()
+	main.Find("script, style, noscript, nav, header, footer, aside, form").Remove()
+	return main.Text()
+}
+
+func simHash(words []string) uint64 {
+	var weights [64]int
+	for shingle := range wordShingles(words, duplicate_shingle) {
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		value := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if value&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var result uint64
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			result |= 1 << uint(bit)
+		}
+	}
+	return result
+}
+
+func NewContentSignature(doc *goquery.Document) ContentSignature {
+	words := textWords(mainText(doc))
+	sum := sha256.Sum256([]byte(strings.Join(words, " ")))
+	return ContentSignature{
+		Hash:    hex.EncodeToString(sum[:16]),
+		SimHash: simHash(words),
+		Words:   len(words),
+	}
+}
+
+func duplicateName(kind int) string {
+	switch kind {
+	case DUPLICATE_EXACT:
+		return "duplicate content"
+	case DUPLICATE_NEAR:
+		return "near-duplicate content"
+	default:
+		return ""
+	}
+}
+
+func duplicateMark(kind int) string {
+	switch kind {
+	case DUPLICATE_EXACT:
+		return "[duplicate] "
+	case DUPLICATE_NEAR:
+		return "[near duplicate] "
+	default:
+		return ""
+	}
+}
+
+func DuplicateGroups(root *UrlTreeStruct) []DuplicateGroup {
+	pages := make([]*UrlTreeStruct, 0)
+	root.Walk(func(uts *UrlTreeStruct) {
+		if uts.Status == STATUS_SUCCESS && uts.Content.Words >= duplicate_min_words {
+			pages = append(pages, uts)
+		}
+	})
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].Url < pages[j].Url
+	})
+
+	groups := make([]DuplicateGroup, 0)
+	byHash := make(map[string][]string)
+	representatives := make([]*UrlTreeStruct, 0)
+	for _, uts := range pages {
+		if _, ok := byHash[uts.Content.Hash]; !ok {
+			representatives = append(representatives, uts)
+		}
+		byHash[uts.Content.Hash] = append(byHash[uts.Content.Hash], uts.Url)
+	}
+	for _, uts := range representatives {
+		if urls := byHash[uts.Content.Hash]; len(urls) > 1 {
+			groups = append(groups, DuplicateGroup{DUPLICATE_EXACT, urls})
+		}
+	}
+
+	parent := make([]int, len(representatives))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range representatives {
+		for j := i + 1; j < len(representatives); j++ {
+			if bits.OnesCount64(representatives[i].Content.SimHash^representatives[j].Content.SimHash) <= simhash_distance {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+	near := make(map[int][]string)
+	members := make(map[int]int)
+	for i, uts := range representatives {
+		clusterRoot := find(i)
+		members[clusterRoot]++
+		near[clusterRoot] = append(near[clusterRoot], byHash[uts.Content.Hash]...)
+	}
+	for i := range representatives {
+		if find(i) == i && members[i] > 1 {
+			urls := near[i]
+			sort.Strings(urls)
+			groups = append(groups, DuplicateGroup{DUPLICATE_NEAR, urls})
+		}
+	}
+	return groups
+}
+
+var duplicateSiteRules = []string{RULE_DUPLICATE_CONTENT, RULE_NEAR_DUPLICATE}
+
+func DuplicateSiteFindings(root *UrlTreeStruct) []Finding {
+	findings := make([]Finding, 0)
+	kinds := make(map[string]int)
+	hashes := make(map[string]string)
+	root.Walk(func(uts *UrlTreeStruct) {
+		hashes[uts.Url] = uts.Content.Hash
+	})
+	for _, group := range DuplicateGroups(root) {
+		rule := RULE_DUPLICATE_CONTENT
+		if group.Kind == DUPLICATE_NEAR {
+			rule = RULE_NEAR_DUPLICATE
+		}
+		for i, url := range group.Urls {
+			if kinds[url] == DUPLICATE_NONE || group.Kind == DUPLICATE_EXACT {
+				kinds[url] = group.Kind
+			}
+			// Near-duplicate findings name a page with different text, the exact copy is reported separately.
+			other := ""
+			for j, candidate := range group.Urls {
+				if j != i && (other == "" || hashes[other] == hashes[url]) {
+					other = candidate
+				}
+			}
+			findings = append(findings, Finding{rule, SEVERITY_WARNING, url,
+				fmt.Sprintf("%s with %s (%d pages in group)", duplicateName(group.Kind), other, len(group.Urls))})
+		}
+	}
+	root.Walk(func(uts *UrlTreeStruct) {
+		uts.Duplicate = kinds[uts.Url]
+	})
+	sortFindings(findings)
+	return findings
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	nurl "net/url"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const (
+	FAILURE_NONE = iota
+	FAILURE_TIMEOUT
+	FAILURE_DNS
+	FAILURE_REFUSED
+	FAILURE_RESET
+	FAILURE_TLS
+	FAILURE_REDIRECTS
+	FAILURE_INVALID_URL
+	FAILURE_TRUNCATED
+	FAILURE_OTHER
+)
+
+func failureName(failure int) string {
+	switch failure {
+	case FAILURE_NONE:
+		return "No failure"
+	case FAILURE_TIMEOUT:
+		return "Timeout"
+	case FAILURE_DNS:
+		return "DNS not found"
+	case FAILURE_REFUSED:
+		return "Connection refused"
+	case FAILURE_RESET:
+		return "Connection reset"
+	case FAILURE_TLS:
+		return "TLS handshake"
+	case FAILURE_REDIRECTS:
+		return "Too many redirects"
+	case FAILURE_INVALID_URL:
+		return "Invalid URL"
+	case FAILURE_TRUNCATED:
+		return "Body truncated"
+	default:
+		return "Network failure"
+	}
+}
+
+func failureTooltip(failure int, message string) string {
+	if failure == FAILURE_NONE {
+		return ""
+	}
+	return fmt.Sprintf("%s: %s", failureName(failure), message)
+}
+
+func failureStatus(failure int) int {
+	if failure == FAILURE_TIMEOUT {
+		return STATUS_LONGWAIT
+	}
+	return STATUS_FAILURE
+}
+
+func classifyError(err error) int {
+	if err == nil {
+		return FAILURE_NONE
+	}
+	message := strings.ToLower(err.Error())
+
+	var dnsErr *net.DNSError
+	var hostnameErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	var urlErr *nurl.Error
+
+	switch {
+	case errors.Is(err, errTooManyRedirects):
+		return FAILURE_REDIRECTS
+	case os.IsTimeout(err) || errors.Is(err, context.DeadlineExceeded):
+		return FAILURE_TIMEOUT
+	case errors.As(err, &dnsErr):
+		return FAILURE_DNS
+	case errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(message, "connection refused") ||
+		strings.Contains(message, "actively refused"):
+		return FAILURE_REFUSED
+	case errors.Is(err, syscall.ECONNRESET) || strings.Contains(message, "connection reset") ||
+		strings.Contains(message, "forcibly closed"):
+		return FAILURE_RESET
+	case errors.As(err, &hostnameErr) || errors.As(err, &authorityErr) || errors.As(err, &invalidErr) ||
+		errors.As(err, &recordErr) || strings.Contains(message, "tls:") || strings.Contains(message, "x509:") ||
+		strings.Contains(message, "http response to https client"):
+		return FAILURE_TLS
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return FAILURE_TRUNCATED
+	case errors.Is(err, io.EOF):
+		return FAILURE_RESET
+	case strings.Contains(message, "unsupported protocol scheme") || strings.Contains(message, "no host in request"):
+		return FAILURE_INVALID_URL
+	case errors.As(err, &urlErr) && urlErr.Op == "parse":
+		return FAILURE_INVALID_URL
+	default:
+		return FAILURE_OTHER
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	SEVERITY_INFO = iota
+	SEVERITY_WARNING
+	SEVERITY_ERROR
+)
+
+type Finding struct {
+	Rule     string
+	Severity int
+	Url      string
+	Message  string
+}
+
+func severityName(severity int) string {
+	switch severity {
+	case SEVERITY_INFO:
+		return "INFO"
+	case SEVERITY_WARNING:
+		return "WARNING"
+	case SEVERITY_ERROR:
+		return "ERROR"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s", severityName(f.Severity), f.Rule, f.Url, f.Message)
+}
+
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+		if findings[i].Url != findings[j].Url {
+			return findings[i].Url < findings[j].Url
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+}
+
+func shortenText(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	return string(runes[:maxLength]) + "..."
+}
+
+func collectFindings(root *UrlTreeStruct, prefixes ...string) []Finding {
+	findings := make([]Finding, 0)
+	root.Walk(func(uts *UrlTreeStruct) {
+		for _, f := range uts.Findings {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(f.Rule, prefix) {
+					findings = append(findings, f)
+					break
+				}
+			}
+		}
+	})
+	return findings
+}
+
+type hostRuleCount struct {
+	host string
+	rule string
+	urls int
+}
+
+func summariseByHost(findings []Finding) []hostRuleCount {
+	counts := make(map[string]map[string]map[string]bool)
+	for _, f := range findings {
+		parsed, err := nurl.Parse(f.Url)
+		if err != nil {
+			continue
+		}
+		if counts[parsed.Host] == nil {
+			counts[parsed.Host] = make(map[string]map[string]bool)
+		}
+		if counts[parsed.Host][f.Rule] == nil {
+			counts[parsed.Host][f.Rule] = make(map[string]bool)
+		}
+		counts[parsed.Host][f.Rule][f.Url] = true
+	}
+	summary := make([]hostRuleCount, 0)
+	for host, rules := range counts {
+		for rule, urls := range rules {
+			summary = append(summary, hostRuleCount{host, rule, len(urls)})
+		}
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].host != summary[j].host {
+			return summary[i].host < summary[j].host
+		}
+		return summary[i].rule < summary[j].rule
+	})
+	return summary
+}
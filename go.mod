@@ -7,11 +7,11 @@ require (
 	github.com/gotk3/gotk3 v0.6.1
 )
 
-require golang.org/x/text v0.3.6 // indirect
+require golang.org/x/text v0.3.6
 
 require (
 	github.com/andybalholm/cascadia v1.3.1 // indirect
 	github.com/sekimura/go-normalize-url v0.0.0-20150113070447-c2b8a31b72ab
-	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8 // indirect
+	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8
 	golang.org/x/sync v0.0.0-20220722155255-886fb9371eb4
 )
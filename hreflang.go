@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/text/language"
+)
+
+const (
+	RULE_HREFLANG_CODE       = "hreflang-invalid-code"
+	RULE_HREFLANG_RECIPROCAL = "hreflang-not-reciprocal"
+	RULE_HREFLANG_TARGET     = "hreflang-bad-target"
+	RULE_HREFLANG_X_DEFAULT  = "hreflang-no-x-default"
+	RULE_HREFLANG_SELF       = "hreflang-no-self-reference"
+
+	hreflang_x_default = "x-default"
+)
+
+var hreflangRegexp = regexp.MustCompile(`^([A-Za-z]{2,3})(-[A-Za-z]{4})?(-([A-Za-z]{2}|[0-9]{3}))?$`)
+
+type HreflangLink struct {
+	Lang string
+	Url  string
+}
+
+type HreflangCluster struct {
+	Pages map[string][]HreflangLink
+	Urls  []string
+}
+
+func hreflangLinks(doc *goquery.Document, base nurl.URL) []HreflangLink {
+	links := make([]HreflangLink, 0)
+	doc.Find("link[hreflang][href]").Each(func(i int, link *goquery.Selection) {
+		if !strings.Contains(" "+strings.ToLower(link.AttrOr("rel", ""))+" ", " alternate ") {
+			return
+		}
+		target, err := base.Parse(link.AttrOr("href", ""))
+		if err != nil {
+			return
+		}
+		target.Fragment = ""
+		links = append(links, HreflangLink{strings.TrimSpace(link.AttrOr("hreflang", "")), target.String()})
+	})
+	return links
+}
+
+func hreflangCodeProblem(code string) string {
+	if strings.EqualFold(code, hreflang_x_default) {
+		return ""
+	}
+	parts := hreflangRegexp.FindStringSubmatch(code)
+	if parts == nil {
+		return fmt.Sprintf("%q is not in language[-script][-region] form", code)
+	}
+	tag, err := language.Parse(code)
+	if err != nil {
+		return fmt.Sprintf("%q: %s", code, err)
+	}
+	if base, _ := tag.Base(); !strings.EqualFold(base.String(), parts[1]) {
+		return fmt.Sprintf("%q should use language code %s", code, base)
+	}
+	if parts[4] != "" {
+		if region, _ := tag.Region(); !strings.EqualFold(region.String(), parts[4]) {
+			return fmt.Sprintf("%q should use region code %s", code, region)
+		}
+	}
+	return ""
+}
+
+func HreflangClusters(root *UrlTreeStruct) []HreflangCluster {
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(key string) string {
+		if p, ok := parent[key]; ok && p != key {
+			parent[key] = find(p)
+			return parent[key]
+		}
+		parent[key] = key
+		return key
+	}
+	pages := make(map[string][]HreflangLink)
+	root.Walk(func(uts *UrlTreeStruct) {
+		if len(uts.Seo.Hreflang) == 0 {
+			return
+		}
+		pages[uts.Url] = uts.Seo.Hreflang
+		for _, link := range uts.Seo.Hreflang {
+			parent[find(pageKey(link.Url))] = find(uts.Url)
+		}
+	})
+
+	byRoot := make(map[string]*HreflangCluster)
+	for key := range parent {
+		clusterRoot := find(key)
+		cluster, ok := byRoot[clusterRoot]
+		if !ok {
+			cluster = &HreflangCluster{Pages: make(map[string][]HreflangLink)}
+			byRoot[clusterRoot] = cluster
+		}
+		cluster.Urls = append(cluster.Urls, key)
+		if links, ok := pages[key]; ok {
+			cluster.Pages[key] = links
+		}
+	}
+	clusters := make([]HreflangCluster, 0, len(byRoot))
+	for _, cluster := range byRoot {
+		sort.Strings(cluster.Urls)
+		clusters = append(clusters, *cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Urls[0] < clusters[j].Urls[0]
+	})
+	return clusters
+}
+
+func (cluster HreflangCluster) SortedPages() []string {
+	pages := make([]string, 0, len(cluster.Pages))
+	for page := range cluster.Pages {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	return pages
+}
+
+func (cluster HreflangCluster) Findings(targetProblems map[string]string) []Finding {
+	findings := make([]Finding, 0)
+	hasXDefault := false
+	for page, links := range cluster.Pages {
+		self := false
+		for _, link := range links {
+			key := pageKey(link.Url)
+			self = self || key == page
+			if strings.EqualFold(link.Lang, hreflang_x_default) {
+				hasXDefault = true
+			}
+			if problem := hreflangCodeProblem(link.Lang); problem != "" {
+				findings = append(findings, Finding{RULE_HREFLANG_CODE, SEVERITY_ERROR, page, problem})
+			}
+			if problem, ok := targetProblems[link.Url]; ok {
+				findings = append(findings, Finding{RULE_HREFLANG_TARGET, SEVERITY_ERROR, page,
+					fmt.Sprintf("hreflang %s target %s %s", link.Lang, link.Url, problem)})
+			}
+			if key == page {
+				continue
+			}
+			targetLinks, crawled := cluster.Pages[key]
+			reciprocal := false
+			for _, back := range targetLinks {
+				reciprocal = reciprocal || pageKey(back.Url) == page
+			}
+			if crawled && !reciprocal {
+				findings = append(findings, Finding{RULE_HREFLANG_RECIPROCAL, SEVERITY_ERROR, page,
+					fmt.Sprintf("hreflang %s target %s does not link back", link.Lang, link.Url)})
+			}
+		}
+		if !self {
+			findings = append(findings, Finding{RULE_HREFLANG_SELF, SEVERITY_WARNING, page, "hreflang set does not reference the page itself"})
+		}
+	}
+	if pages := cluster.SortedPages(); !hasXDefault && len(pages) > 0 {
+		findings = append(findings, Finding{RULE_HREFLANG_X_DEFAULT, SEVERITY_WARNING, pages[0],
+			fmt.Sprintf("cluster of %d URLs has no x-default", len(cluster.Urls))})
+	}
+	sortFindings(findings)
+	return findings
+}
+
+var hreflangSiteRules = []string{RULE_HREFLANG_CODE, RULE_HREFLANG_RECIPROCAL, RULE_HREFLANG_TARGET, RULE_HREFLANG_X_DEFAULT, RULE_HREFLANG_SELF}
+
+func HreflangSiteFindings(root *UrlTreeStruct) []Finding {
+	clusters := HreflangClusters(root)
+	targets := make([]string, 0)
+	for _, cluster := range clusters {
+		for _, links := range cluster.Pages {
+			for _, link := range links {
+				targets = append(targets, link.Url)
+			}
+		}
+	}
+	targetProblems := probeDirectStatus(targets)
+	findings := make([]Finding, 0)
+	for _, cluster := range clusters {
+		findings = append(findings, cluster.Findings(targetProblems)...)
+	}
+	return findings
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	nurl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	RULE_IMAGE_OVERSIZED = "image-oversized"
+	RULE_IMAGE_HEAVY     = "image-heavy"
+	RULE_IMAGE_SRCSET    = "image-srcset-width"
+
+	image_oversize_factor = 2.0
+	image_srcset_slack    = 0.1
+)
+
+type ImageInfo struct {
+	Width  int
+	Height int
+	Format string
+	Size   int64
+	Err    error
+}
+
+type imageProbe struct {
+	once sync.Once
+	info ImageInfo
+}
+
+var (
+	imageProbes    = make(map[string]*imageProbe)
+	imageProbesMtx sync.Mutex
+)
+
+func resetImageProbes() {
+	imageProbesMtx.Lock()
+	imageProbes = make(map[string]*imageProbe)
+	imageProbesMtx.Unlock()
+}
+
+func fetchImageInfo(url string) ImageInfo {
+	client := newHttpClient(time.Second*30, limitRedirects)
+	resp, wire, _, err := measuredGetRequest(client, url, nil)
+	if err != nil {
+		return ImageInfo{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return ImageInfo{Err: fmt.Errorf("status %d", resp.StatusCode)}
+	}
+	body := io.LimitReader(wire, max_measured_size)
+	info := ImageInfo{}
+	config, format, err := image.DecodeConfig(body)
+	if err == nil {
+		info.Width, info.Height, info.Format = config.Width, config.Height, format
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		info.Err = err
+	}
+	info.Size = wire.Size()
+	return info
+}
+
+func imageInfo(url string) ImageInfo {
+	imageProbesMtx.Lock()
+	probe, ok := imageProbes[url]
+	if !ok {
+		probe = &imageProbe{}
+		imageProbes[url] = probe
+	}
+	imageProbesMtx.Unlock()
+	probe.once.Do(func() {
+		probe.info = fetchImageInfo(url)
+	})
+	return probe.info
+}
+
+type imageCandidate struct {
+	url           string
+	density       float64
+	declaredWidth int
+}
+
+func dimensionAttr(img *goquery.Selection, name string) int {
+	value, _ := img.Attr(name)
+	size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "px"))
+	if err != nil || size <= 0 {
+		return 0
+	}
+	return size
+}
+
+func imageCandidates(img *goquery.Selection, base nurl.URL) []imageCandidate {
+	candidates := make([]imageCandidate, 0)
+	if src, ok := img.Attr("src"); ok && strings.TrimSpace(src) != "" {
+		if srcUrl, err := base.Parse(src); err == nil {
+			candidates = append(candidates, imageCandidate{srcUrl.String(), 1, 0})
+		}
+	}
+	srcset, _ := img.Attr("srcset")
+	for _, entry := range strings.Split(srcset, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		entryUrl, err := base.Parse(fields[0])
+		if err != nil {
+			continue
+		}
+		candidate := imageCandidate{entryUrl.String(), 1, 0}
+		if len(fields) > 1 {
+			descriptor := strings.ToLower(fields[1])
+			if strings.HasSuffix(descriptor, "w") {
+				candidate.declaredWidth, _ = strconv.Atoi(strings.TrimSuffix(descriptor, "w"))
+			} else if density, err := strconv.ParseFloat(strings.TrimSuffix(descriptor, "x"), 64); err == nil && density > 0 {
+				candidate.density = density
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+func checkImageCandidate(candidate imageCandidate, width, height int, settings ScanSettings) []Finding {
+	findings := make([]Finding, 0)
+	add := func(rule string, severity int, message string) {
+		findings = append(findings, Finding{rule, severity, candidate.url, message})
+	}
+	info := imageInfo(candidate.url)
+	if info.Err != nil {
+		return findings
+	}
+	if settings.MaxImageKiB > 0 && info.Size > int64(settings.MaxImageKiB)*1024 {
+		add(RULE_IMAGE_HEAVY, SEVERITY_WARNING, fmt.Sprintf("image is %s, limit is %d KiB",
+			formatSize(info.Size), settings.MaxImageKiB))
+	}
+	if info.Width == 0 {
+		return findings
+	}
+	if candidate.declaredWidth > 0 {
+		slack := float64(candidate.declaredWidth) * image_srcset_slack
+		if diff := float64(info.Width - candidate.declaredWidth); diff > slack || diff < -slack {
+			add(RULE_IMAGE_SRCSET, SEVERITY_WARNING, fmt.Sprintf("srcset declares %dw, image is %d px wide",
+				candidate.declaredWidth, info.Width))
+		}
+		return findings
+	}
+	switch {
+	case width > 0 && float64(info.Width) > float64(width)*candidate.density*image_oversize_factor:
+		add(RULE_IMAGE_OVERSIZED, SEVERITY_WARNING, fmt.Sprintf("image is %dx%d px, rendered at width %d",
+			info.Width, info.Height, width))
+	case width == 0 && height > 0 && float64(info.Height) > float64(height)*candidate.density*image_oversize_factor:
+		add(RULE_IMAGE_OVERSIZED, SEVERITY_WARNING, fmt.Sprintf("image is %dx%d px, rendered at height %d",
+			info.Width, info.Height, height))
+	}
+	return findings
+}
+
+func checkImageSizes(doc *goquery.Document, base nurl.URL, urlContainer *UrlTreeStruct, group *errgroup.Group) {
+	settings := currentSettings()
+	checked := make(map[string]bool)
+	doc.Find("img").Each(func(i int, img *goquery.Selection) {
+		width := dimensionAttr(img, "width")
+		height := dimensionAttr(img, "height")
+		for _, candidate := range imageCandidates(img, base) {
+			key := fmt.Sprintf("%s %d %d %d", candidate.url, width, height, candidate.declaredWidth)
+			if checked[key] {
+				continue
+			}
+			checked[key] = true
+			c := candidate
+			group.Go(func() error {
+				for _, f := range checkImageCandidate(c, width, height, settings) {
+					urlContainer.AppendFinding(f)
+				}
+				return nil
+			})
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const (
+	max_path_elements = 4
+	css_url_attr      = "url()"
+)
+
+// LinkContext tells where on the page an inner URL was found.
+type LinkContext struct {
+	Text string
+	Tag  string
+	Attr string
+	Rel  string
+	Path string
+	Line int
+}
+
+// PageSource maps parsed elements back to the line of their start tag.
+type PageSource struct {
+	lines map[*html.Node]int
+}
+
+var lineAttributes = map[string]bool{"href": true, "src": true, "content": true, "data": true, "poster": true, "style": true}
+
+func lineKey(tag, attr, value string) string {
+	return tag + "\x00" + attr + "\x00" + value
+}
+
+// NewPageSource tokenizes the raw page once more to find start tag lines. Tags
+// are matched to parsed elements by name and URL attribute in document order,
+// so elements inserted by the parser itself simply have no line.
+func NewPageSource(raw []byte, doc *goquery.Document) *PageSource {
+	positions := make(map[string][]int)
+	tokenizer := html.NewTokenizer(bytes.NewReader(raw))
+	line := 1
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		start := line
+		line += bytes.Count(tokenizer.Raw(), []byte("\n"))
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		token := tokenizer.Token()
+		for _, attr := range token.Attr {
+			if lineAttributes[attr.Key] {
+				key := lineKey(token.Data, attr.Key, attr.Val)
+				positions[key] = append(positions[key], start)
+			}
+		}
+	}
+
+	source := &PageSource{lines: make(map[*html.Node]int)}
+	for _, root := range doc.Nodes {
+		var visit func(*html.Node)
+		visit = func(node *html.Node) {
+			if node.Type == html.ElementNode {
+				for _, attr := range node.Attr {
+					key := lineKey(node.Data, attr.Key, attr.Val)
+					if queue := positions[key]; lineAttributes[attr.Key] && len(queue) > 0 {
+						if _, ok := source.lines[node]; !ok {
+							source.lines[node] = queue[0]
+						}
+						positions[key] = queue[1:]
+					}
+				}
+			}
+			for child := node.FirstChild; child != nil; child = child.NextSibling {
+				visit(child)
+			}
+		}
+		visit(root)
+	}
+	return source
+}
+
+func pathElement(node *html.Node) string {
+	for _, attr := range node.Attr {
+		if attr.Key == "id" && attr.Val != "" {
+			return node.Data + "#" + attr.Val
+		}
+	}
+	for _, attr := range node.Attr {
+		if attr.Key == "class" {
+			if classes := strings.Fields(attr.Val); len(classes) > 0 {
+				return node.Data + "." + classes[0]
+			}
+		}
+	}
+	index, same := 0, 0
+	for sibling := node.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type == html.ElementNode && sibling.Data == node.Data {
+			same++
+			if sibling == node {
+				index = same
+			}
+		}
+	}
+	if same > 1 {
+		return fmt.Sprintf("%s:nth-of-type(%d)", node.Data, index)
+	}
+	return node.Data
+}
+
+// elementPath is a short CSS-selector-like path, it stops at the nearest id.
+func elementPath(node *html.Node) string {
+	parts := make([]string, 0, max_path_elements)
+	for current := node; current != nil && current.Type == html.ElementNode && current.Parent != nil; current = current.Parent {
+		part := pathElement(current)
+		parts = append([]string{part}, parts...)
+		if strings.Contains(part, "#") || len(parts) == max_path_elements || current.Data == "body" || current.Data == "head" {
+			break
+		}
+	}
+	return strings.Join(parts, " > ")
+}
+
+func elementText(s *goquery.Selection, tag string) string {
+	switch tag {
+	case "a":
+		return anchorText(s)
+	case "img":
+		return collapseSpaces(s.AttrOr("alt", ""))
+	case "meta":
+		return s.AttrOr("property", s.AttrOr("name", ""))
+	}
+	return collapseSpaces(s.AttrOr("title", ""))
+}
+
+func (ps *PageSource) Context(s *goquery.Selection, attr string) LinkContext {
+	if ps == nil || s.Length() == 0 {
+		return LinkContext{}
+	}
+	node := s.Get(0)
+	return LinkContext{
+		Text: elementText(s, node.Data),
+		Tag:  node.Data,
+		Attr: attr,
+		Rel:  strings.Join(strings.Fields(s.AttrOr("rel", "")), " "),
+		Path: elementPath(node),
+		Line: ps.lines[node],
+	}
+}
+
+func (lc LinkContext) Element() string {
+	if lc.Tag == "" {
+		return ""
+	}
+	element := fmt.Sprintf("%s[%s]", lc.Tag, lc.Attr)
+	if lc.Attr == css_url_attr {
+		element = lc.Tag + " " + css_url_attr
+	}
+	if lc.Rel != "" {
+		element += fmt.Sprintf(" rel=%s", lc.Rel)
+	}
+	return element
+}
+
+func (lc LinkContext) Location() string {
+	if lc.Tag == "" {
+		return ""
+	}
+	if lc.Line > 0 {
+		return fmt.Sprintf("%s at line %d", lc.Element(), lc.Line)
+	}
+	return lc.Element()
+}
+
+func (lc LinkContext) String() string {
+	if lc.Tag == "" {
+		return ""
+	}
+	lines := []string{"Found in " + lc.Location()}
+	if lc.Text != "" {
+		lines = append(lines, fmt.Sprintf("Text: %q", lc.Text))
+	}
+	if lc.Path != "" {
+		lines = append(lines, "Path: "+lc.Path)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	GRAPH_FORMAT_DOT     = "dot"
+	GRAPH_FORMAT_GRAPHML = "graphml"
+
+	max_anchor_length = 80
+	graphml_xmlns     = "http://graphml.graphdrawing.org/xmlns"
+)
+
+type GraphNode struct {
+	Id     string
+	Url    string
+	Status int
+	Pages  int
+	Broken int
+}
+
+type GraphEdge struct {
+	From    string
+	To      string
+	Count   int
+	Anchors []string
+}
+
+type LinkGraphExport struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+}
+
+func collapseSpaces(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// anchorText is the visible text of a link, or the alt text of its images.
+func anchorText(a *goquery.Selection) string {
+	text := collapseSpaces(a.Text())
+	if text == "" {
+		alts := make([]string, 0)
+		a.Find("img[alt]").Each(func(i int, img *goquery.Selection) {
+			alts = append(alts, img.AttrOr("alt", ""))
+		})
+		text = collapseSpaces(strings.Join(alts, " "))
+	}
+	if text == "" {
+		text = collapseSpaces(a.AttrOr("title", ""))
+	}
+	if runes := []rune(text); len(runes) > max_anchor_length {
+		text = string(runes[:max_anchor_length-3]) + "..."
+	}
+	return text
+}
+
+func isBrokenStatus(status int) bool {
+	return status != STATUS_SUCCESS && status != STATUS_NO_INFO
+}
+
+// graphNodeOf returns the page a node stands for. With collapse, pages without
+// children are merged into their directory, the parent page in the tree.
+func graphNodeOf(uts, scope *UrlTreeStruct, collapse bool) *UrlTreeStruct {
+	if collapse && uts != scope && len(uts.Childs) == 0 && uts.Parent != nil {
+		return uts.Parent
+	}
+	return uts
+}
+
+// BuildLinkGraph collects the links between pages below scope.
+func BuildLinkGraph(scope *UrlTreeStruct, collapse bool) LinkGraphExport {
+	pages := make(map[string]*UrlTreeStruct)
+	scope.Walk(func(uts *UrlTreeStruct) {
+		pages[uts.Url] = uts
+	})
+
+	nodes := make(map[*UrlTreeStruct]*GraphNode)
+	order := make([]*UrlTreeStruct, 0)
+	scope.Walk(func(uts *UrlTreeStruct) {
+		owner := graphNodeOf(uts, scope, collapse)
+		node, ok := nodes[owner]
+		if !ok {
+			node = &GraphNode{Url: owner.Url, Status: owner.Status}
+			nodes[owner] = node
+			order = append(order, owner)
+		}
+		node.Pages++
+		if isBrokenStatus(uts.Status) {
+			node.Broken++
+		}
+	})
+	sort.Slice(order, func(i, j int) bool {
+		return order[i].Url < order[j].Url
+	})
+	graph := LinkGraphExport{Nodes: make([]GraphNode, 0, len(order)), Edges: make([]GraphEdge, 0)}
+	index := make(map[*UrlTreeStruct]int, len(order))
+	for i, owner := range order {
+		index[owner] = i
+		nodes[owner].Id = "n" + strconv.Itoa(i)
+		graph.Nodes = append(graph.Nodes, *nodes[owner])
+	}
+
+	edges := make(map[[2]int]*PageLink)
+	scope.Walk(func(uts *UrlTreeStruct) {
+		from := index[graphNodeOf(uts, scope, collapse)]
+		for _, link := range uts.OutLinks {
+			target, ok := pages[link.Url]
+			if !ok {
+				continue
+			}
+			to := index[graphNodeOf(target, scope, collapse)]
+			if from == to {
+				continue
+			}
+			edge, ok := edges[[2]int{from, to}]
+			if !ok {
+				edge = &PageLink{Anchors: make([]string, 0)}
+				edges[[2]int{from, to}] = edge
+			}
+			edge.Count += link.Count
+			for _, anchor := range link.Anchors {
+				edge.addAnchor(anchor)
+			}
+		}
+	})
+	keys := make([][2]int, 0, len(edges))
+	for key := range edges {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, key := range keys {
+		edge := edges[key]
+		graph.Edges = append(graph.Edges, GraphEdge{graph.Nodes[key[0]].Id, graph.Nodes[key[1]].Id, edge.Count, edge.Anchors})
+	}
+	return graph
+}
+
+func (gn GraphNode) Label() string {
+	if gn.Pages <= 1 {
+		return gn.Url
+	}
+	return fmt.Sprintf("%s (%d pages, %d broken)", gn.Url, gn.Pages, gn.Broken)
+}
+
+func graphNodeColor(gn GraphNode) string {
+	switch {
+	case gn.Status == STATUS_NO_INFO:
+		return "lightgrey"
+	case gn.Status != STATUS_SUCCESS:
+		return "lightcoral"
+	case gn.Broken > 0:
+		return "orange"
+	default:
+		return "palegreen"
+	}
+}
+
+func dotQuote(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, `"`, `\"`)
+	return `"` + strings.ReplaceAll(text, "\n", `\n`) + `"`
+}
+
+func (graph LinkGraphExport) Dot() string {
+	var sb strings.Builder
+	sb.WriteString("digraph links {\n")
+	sb.WriteString("  node [shape=box, style=filled];\n")
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(&sb, "  %s [label=%s, URL=%s, status=%d, pages=%d, fillcolor=%s];\n",
+			node.Id, dotQuote(node.Label()), dotQuote(node.Url), node.Status, node.Pages, graphNodeColor(node))
+	}
+	for _, edge := range graph.Edges {
+		label := strings.Join(edge.Anchors, "\n")
+		if edge.Count > 1 {
+			label = fmt.Sprintf("%s\n(%d links)", label, edge.Count)
+		}
+		fmt.Fprintf(&sb, "  %s -> %s [label=%s, count=%d, weight=%d];\n",
+			edge.From, edge.To, dotQuote(strings.TrimSpace(label)), edge.Count, edge.Count)
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+type graphmlKey struct {
+	Id       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	Name     string `xml:"attr.name,attr"`
+	DataType string `xml:"attr.type,attr"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphmlNode struct {
+	Id   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphmlDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   struct {
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphmlNode `xml:"node"`
+		Edges       []graphmlEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+func (graph LinkGraphExport) GraphML() graphmlDocument {
+	document := graphmlDocument{Xmlns: graphml_xmlns, Keys: []graphmlKey{
+		{"url", "node", "url", "string"},
+		{"label", "node", "label", "string"},
+		{"status", "node", "status", "int"},
+		{"pages", "node", "pages", "int"},
+		{"broken", "node", "broken", "int"},
+		{"count", "edge", "count", "int"},
+		{"anchors", "edge", "anchors", "string"},
+	}}
+	document.Graph.EdgeDefault = "directed"
+	for _, node := range graph.Nodes {
+		document.Graph.Nodes = append(document.Graph.Nodes, graphmlNode{node.Id, []graphmlData{
+			{"url", node.Url},
+			{"label", node.Label()},
+			{"status", strconv.Itoa(node.Status)},
+			{"pages", strconv.Itoa(node.Pages)},
+			{"broken", strconv.Itoa(node.Broken)},
+		}})
+	}
+	for _, edge := range graph.Edges {
+		document.Graph.Edges = append(document.Graph.Edges, graphmlEdge{edge.From, edge.To, []graphmlData{
+			{"count", strconv.Itoa(edge.Count)},
+			{"anchors", strings.Join(edge.Anchors, " | ")},
+		}})
+	}
+	return document
+}
+
+func WriteLinkGraph(filePath, format string, graph LinkGraphExport) error {
+	if format == GRAPH_FORMAT_GRAPHML {
+		return writeXmlFile(filePath, graph.GraphML())
+	}
+	return os.WriteFile(filePath, []byte(graph.Dot()), 0644)
+}
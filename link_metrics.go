@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	DEPTH_UNREACHABLE = -1
+
+	RULE_LINKS_DEEP   = "links-deep-page"
+	RULE_LINKS_ORPHAN = "links-orphan-page"
+
+	pagerank_damping    = 0.85
+	pagerank_iterations = 100
+	pagerank_tolerance  = 1e-9
+	max_link_anchors    = 5
+)
+
+const (
+	TREE_VIEW_ALL    = "all"
+	TREE_VIEW_DEEP   = "deep"
+	TREE_VIEW_ORPHAN = "orphan"
+	TREE_VIEW_WEAK   = "weak"
+)
+
+type LinkMetrics struct {
+	Depth        int
+	InboundLinks int
+	LinkingPages int
+	PageRank     float64
+	Score        int
+}
+
+type PageLink struct {
+	Url     string
+	Count   int
+	Anchors []string
+}
+
+var (
+	crawlLinks    = make(map[string]map[string]*PageLink)
+	crawlLinksMtx sync.Mutex
+)
+
+func resetCrawlLinks() {
+	crawlLinksMtx.Lock()
+	crawlLinks = make(map[string]map[string]*PageLink)
+	crawlLinksMtx.Unlock()
+}
+
+// recordCrawlLink counts a link from one crawled page to another, both given
+// as page keys as they are stored in the list of scanned pages.
+func recordCrawlLink(from, to, anchor string) {
+	if from == to {
+		return
+	}
+	crawlLinksMtx.Lock()
+	defer crawlLinksMtx.Unlock()
+	targets, ok := crawlLinks[from]
+	if !ok {
+		targets = make(map[string]*PageLink)
+		crawlLinks[from] = targets
+	}
+	link, ok := targets[to]
+	if !ok {
+		link = &PageLink{Url: to, Anchors: make([]string, 0)}
+		targets[to] = link
+	}
+	link.Count++
+	link.addAnchor(anchor)
+}
+
+func (pl *PageLink) addAnchor(anchor string) {
+	if anchor == "" || len(pl.Anchors) >= max_link_anchors {
+		return
+	}
+	for _, known := range pl.Anchors {
+		if known == anchor {
+			return
+		}
+	}
+	pl.Anchors = append(pl.Anchors, anchor)
+}
+
+// LinkGraph returns the number of links between pages of the tree.
+func LinkGraph(root *UrlTreeStruct) map[string]map[string]int {
+	links := make(map[string]map[string]int)
+	root.Walk(func(uts *UrlTreeStruct) {
+		if len(uts.OutLinks) == 0 {
+			return
+		}
+		targets := make(map[string]int, len(uts.OutLinks))
+		for _, link := range uts.OutLinks {
+			targets[link.Url] = link.Count
+		}
+		links[uts.Url] = targets
+	})
+	return links
+}
+
+func (lm LinkMetrics) String() string {
+	depth := "not reachable by links from the start page"
+	if lm.Depth != DEPTH_UNREACHABLE {
+		depth = fmt.Sprintf("%d clicks from the start page", lm.Depth)
+	}
+	return fmt.Sprintf("Links: %s, %d inbound links from %d pages, PageRank %.5f (score %d)",
+		depth, lm.InboundLinks, lm.LinkingPages, lm.PageRank, lm.Score)
+}
+
+func clickDepths(start string, links map[string]map[string]int, pages map[string]bool) map[string]int {
+	depths := map[string]int{start: 0}
+	queue := []string{start}
+	for len(queue) > 0 {
+		page := queue[0]
+		queue = queue[1:]
+		targets := make([]string, 0, len(links[page]))
+		for target := range links[page] {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+		for _, target := range targets {
+			if _, seen := depths[target]; seen || !pages[target] {
+				continue
+			}
+			depths[target] = depths[page] + 1
+			queue = append(queue, target)
+		}
+	}
+	return depths
+}
+
+func pageRank(nodes []string, links map[string]map[string]int) map[string]float64 {
+	count := float64(len(nodes))
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		index[node] = i
+	}
+	outgoing := make([][]int, len(nodes))
+	for i, node := range nodes {
+		for target := range links[node] {
+			if j, ok := index[target]; ok && j != i {
+				outgoing[i] = append(outgoing[i], j)
+			}
+		}
+	}
+	rank := make([]float64, len(nodes))
+	for i := range rank {
+		rank[i] = 1 / count
+	}
+	for iteration := 0; iteration < pagerank_iterations; iteration++ {
+		next := make([]float64, len(nodes))
+		dangling := 0.0
+		for i, targets := range outgoing {
+			if len(targets) == 0 {
+				dangling += rank[i]
+				continue
+			}
+			share := rank[i] / float64(len(targets))
+			for _, j := range targets {
+				next[j] += share
+			}
+		}
+		delta := 0.0
+		for i := range next {
+			next[i] = (1-pagerank_damping)/count + pagerank_damping*(next[i]+dangling/count)
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank = next
+		if delta < pagerank_tolerance {
+			break
+		}
+	}
+	result := make(map[string]float64, len(nodes))
+	for i, node := range nodes {
+		result[node] = rank[i]
+	}
+	return result
+}
+
+// ComputeLinkMetrics fills click depth, inbound link counts and PageRank of
+// every page in the tree from the link graph recorded while crawling.
+func ComputeLinkMetrics(root *UrlTreeStruct, links map[string]map[string]int) {
+	nodes := make([]string, 0)
+	pages := make(map[string]bool)
+	root.Walk(func(uts *UrlTreeStruct) {
+		nodes = append(nodes, uts.Url)
+		pages[uts.Url] = true
+	})
+	inbound := make(map[string]int)
+	linking := make(map[string]int)
+	for from, targets := range links {
+		if !pages[from] {
+			continue
+		}
+		for to, count := range targets {
+			if pages[to] && to != from {
+				inbound[to] += count
+				linking[to]++
+			}
+		}
+	}
+	depths := clickDepths(root.Url, links, pages)
+	ranks := pageRank(nodes, links)
+	maxRank := 0.0
+	for _, rank := range ranks {
+		maxRank = math.Max(maxRank, rank)
+	}
+	root.Walk(func(uts *UrlTreeStruct) {
+		depth, ok := depths[uts.Url]
+		if !ok {
+			depth = DEPTH_UNREACHABLE
+		}
+		uts.Metrics = LinkMetrics{
+			Depth:        depth,
+			InboundLinks: inbound[uts.Url],
+			LinkingPages: linking[uts.Url],
+			PageRank:     ranks[uts.Url],
+		}
+		if maxRank > 0 {
+			uts.Metrics.Score = int(math.Round(ranks[uts.Url] / maxRank * 100))
+		}
+	})
+}
+
+func applyCrawlLinks(root *UrlTreeStruct) {
+	crawlLinksMtx.Lock()
+	root.Walk(func(uts *UrlTreeStruct) {
+		uts.OutLinks = make([]PageLink, 0, len(crawlLinks[uts.Url]))
+		for _, link := range crawlLinks[uts.Url] {
+			uts.OutLinks = append(uts.OutLinks, *link)
+		}
+		sort.Slice(uts.OutLinks, func(i, j int) bool {
+			return uts.OutLinks[i].Url < uts.OutLinks[j].Url
+		})
+	})
+	crawlLinksMtx.Unlock()
+	ComputeLinkMetrics(root, LinkGraph(root))
+}
+
+func isDeepPage(uts *UrlTreeStruct, maxDepth int) bool {
+	return maxDepth > 0 && uts.Metrics.Depth > maxDepth
+}
+
+func isOrphanPage(uts *UrlTreeStruct) bool {
+	return uts.Parent != nil && uts.Metrics.Depth == DEPTH_UNREACHABLE
+}
+
+func isWeaklyLinked(uts *UrlTreeStruct) bool {
+	return uts.Parent != nil && uts.Metrics.Depth != DEPTH_UNREACHABLE && uts.Metrics.LinkingPages <= 1
+}
+
+func treeViewMatches(view string, uts *UrlTreeStruct, maxDepth int) bool {
+	switch view {
+	case TREE_VIEW_DEEP:
+		return isDeepPage(uts, maxDepth)
+	case TREE_VIEW_ORPHAN:
+		return isOrphanPage(uts)
+	case TREE_VIEW_WEAK:
+		return isWeaklyLinked(uts)
+	default:
+		return true
+	}
+}
+
+var linkSiteRules = []string{RULE_LINKS_DEEP, RULE_LINKS_ORPHAN}
+
+func LinkSiteFindings(root *UrlTreeStruct, maxDepth int) []Finding {
+	findings := make([]Finding, 0)
+	root.Walk(func(uts *UrlTreeStruct) {
+		if uts.Status != STATUS_SUCCESS {
+			return
+		}
+		if isOrphanPage(uts) {
+			findings = append(findings, Finding{RULE_LINKS_ORPHAN, SEVERITY_WARNING, uts.Url,
+				"page is not reachable by links from the start page"})
+		} else if isDeepPage(uts, maxDepth) {
+			findings = append(findings, Finding{RULE_LINKS_DEEP, SEVERITY_WARNING, uts.Url,
+				fmt.Sprintf("page is %d clicks from the start page (limit %d)", uts.Metrics.Depth, maxDepth)})
+		}
+	})
+	sortFindings(findings)
+	return findings
+}
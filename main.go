@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -23,10 +24,17 @@ var (
 	checkSingleDeepButton *gtk.Button
 	saveButton            *gtk.Button
 	loadButton            *gtk.Button
+	reportButton          *gtk.Button
+	sitemapButton         *gtk.Button
+	sitemapExportButton   *gtk.Button
+	linkGraphButton       *gtk.Button
 	backButton            *gtk.Button
 	selectedUrlLink       *gtk.LinkButton
 	innerUrlTreeView      *gtk.TreeView
 	listStore             *gtk.ListStore
+	innerUrlFilter        *gtk.Entry
+	innerUrlView          *gtk.ComboBoxText
+	urlTreeFilter         *gtk.ComboBoxText
 
 	searchedUrl     string
 	listOfUrls      *[]string
@@ -47,6 +55,13 @@ const (
 	STATUS_TMR        = 429
 	STATUS_ISE        = 500
 	STATUS_ROBOT      = 999
+	STATUS_SOFT404    = 1404
+)
+
+const (
+	INNER_VIEW_ALL      = "all"
+	INNER_VIEW_MIXED    = "mixed"
+	INNER_VIEW_FINDINGS = "findings"
 )
 
 const (
@@ -69,9 +84,9 @@ func save() {
 	if response == int(gtk.RESPONSE_ACCEPT) {
 		fn := dlg.GetFilename()
 		log.Printf("File: %s", fn)
-		var arr []UrlTreeStructCard
-		urlTree.CopyAsList(&arr)
-		writeGob(fn, arr)
+		project := &ProjectCard{Settings: currentSettings(), Tls: tlsAudit}
+		urlTree.CopyAsList(&project.Pages)
+		writeProject(fn, project)
 	}
 	dlg.Destroy()
 	unlockUI()
@@ -85,9 +100,18 @@ func load() {
 	if response == int(gtk.RESPONSE_ACCEPT) {
 		fn := dlg.GetFilename()
 		log.Printf("File: %s", fn)
-		var arr = new([]UrlTreeStructCard)
-		readGob(fn, arr)
-		urlTree = RestoreFromList(arr)
+		project, err := readProject(fn)
+		if err != nil || len(project.Pages) == 0 {
+			log.Println("Unable to load project:", err)
+			dlg.Destroy()
+			unlockUI()
+			return
+		}
+		if err = applySettings(project.Settings); err != nil {
+			log.Println("Project settings not applied:", err)
+		}
+		urlTree = RestoreFromList(&project.Pages)
+		tlsAudit.Restore(project.Tls)
 		searchedUrl = urlTree.Url
 		entry.DeleteText(0, int(entry.GetTextLength()))
 		entry.InsertText(urlTree.Url, 0)
@@ -98,6 +122,156 @@ func load() {
 	unlockUI()
 }
 
+func exportReport() {
+	if urlTree == nil {
+		return
+	}
+	lockUI()
+	dlg, _ := gtk.FileChooserNativeDialogNew("Choose report file", win,
+		gtk.FILE_CHOOSER_ACTION_SAVE, "Export", "Cancel")
+	dlg.SetCurrentName("report.txt")
+	response := dlg.Run()
+	if response == int(gtk.RESPONSE_ACCEPT) {
+		fn := dlg.GetFilename()
+		log.Printf("Report: %s", fn)
+		if err := writeReport(fn, urlTree); err != nil {
+			log.Println("Unable to write report:", err)
+		}
+	}
+	dlg.Destroy()
+	unlockUI()
+}
+
+func exportSitemap() {
+	if urlTree == nil {
+		return
+	}
+	pages := make([]string, 0)
+	if listOfUrls != nil {
+		pages = *listOfUrls
+	} else {
+		urlTree.Walk(func(uts *UrlTreeStruct) {
+			if uts != urlTree {
+				pages = append(pages, uts.Url)
+			}
+		})
+	}
+	lockUI()
+	dlg, _ := gtk.FileChooserNativeDialogNew("Choose sitemap file", win,
+		gtk.FILE_CHOOSER_ACTION_SAVE, "Export", "Cancel")
+	dlg.SetCurrentName("sitemap.xml")
+	response := dlg.Run()
+	if response == int(gtk.RESPONSE_ACCEPT) {
+		fn := dlg.GetFilename()
+		locs := SitemapLocs(urlTree, pages, currentSettings().SitemapLastmod)
+		files, err := WriteSitemap(fn, urlTree.Url, locs)
+		if err != nil {
+			log.Println("Unable to write sitemap:", err)
+			progressChangeWithToolTip(fmt.Sprintf("Unable to write sitemap: %s", err), 0)
+		} else {
+			progressChangeWithToolTip(fmt.Sprintf("Sitemap with %d URLs written to %d files", len(locs), len(files)), 1)
+		}
+	}
+	dlg.Destroy()
+	unlockUI()
+}
+
+func linkGraphOptions() (format string, subtree, collapse, ok bool) {
+	dialog, _ := gtk.DialogNew()
+	dialog.SetTitle("Link graph")
+	dialog.SetPosition(gtk.WIN_POS_CENTER)
+	dialog.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+	dialog.AddButton("Export", gtk.RESPONSE_ACCEPT)
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(5)
+	grid.SetColumnSpacing(5)
+	grid.SetMarginStart(5)
+	grid.SetMarginEnd(5)
+	grid.SetMarginTop(5)
+	grid.SetMarginBottom(5)
+
+	formatCombo, _ := gtk.ComboBoxTextNew()
+	formatCombo.Append(GRAPH_FORMAT_DOT, "Graphviz DOT")
+	formatCombo.Append(GRAPH_FORMAT_GRAPHML, "GraphML")
+	formatCombo.SetActiveID(GRAPH_FORMAT_DOT)
+	attachSettingsRow(grid, 0, "Format", formatCombo)
+	subtreeCheck := newSettingsCheck("Only the subtree of the selected page", selectedUrl != nil)
+	subtreeCheck.SetSensitive(selectedUrl != nil)
+	attachSettingsRow(grid, 1, "", subtreeCheck)
+	collapseCheck := newSettingsCheck("Collapse pages by directory", false)
+	attachSettingsRow(grid, 2, "", collapseCheck)
+
+	area, _ := dialog.GetContentArea()
+	area.Add(grid)
+	dialog.ShowAll()
+	ok = dialog.Run() == gtk.RESPONSE_ACCEPT
+	format, subtree, collapse = formatCombo.GetActiveID(), subtreeCheck.GetActive(), collapseCheck.GetActive()
+	dialog.Destroy()
+	return
+}
+
+func exportLinkGraph() {
+	if urlTree == nil {
+		return
+	}
+	format, subtree, collapse, ok := linkGraphOptions()
+	if !ok {
+		return
+	}
+	scope := urlTree
+	if subtree && selectedUrl != nil {
+		scope = selectedUrl
+	}
+	lockUI()
+	dlg, _ := gtk.FileChooserNativeDialogNew("Choose link graph file", win,
+		gtk.FILE_CHOOSER_ACTION_SAVE, "Export", "Cancel")
+	dlg.SetCurrentName("links." + format)
+	response := dlg.Run()
+	if response == int(gtk.RESPONSE_ACCEPT) {
+		fn := dlg.GetFilename()
+		graph := BuildLinkGraph(scope, collapse)
+		if err := WriteLinkGraph(fn, format, graph); err != nil {
+			log.Println("Unable to write link graph:", err)
+			progressChangeWithToolTip(fmt.Sprintf("Unable to write link graph: %s", err), 0)
+		} else {
+			progressChangeWithToolTip(fmt.Sprintf("Link graph with %d nodes and %d edges written", len(graph.Nodes), len(graph.Edges)), 1)
+		}
+	}
+	dlg.Destroy()
+	unlockUI()
+}
+
+func startSitemapValidation() {
+	if urlTree == nil {
+		return
+	}
+	dlg, _ := gtk.FileChooserNativeDialogNew("Choose sitemap report file", win,
+		gtk.FILE_CHOOSER_ACTION_SAVE, "Validate", "Cancel")
+	dlg.SetCurrentName("sitemap_report.txt")
+	response := dlg.Run()
+	fn := dlg.GetFilename()
+	dlg.Destroy()
+	if response != int(gtk.RESPONSE_ACCEPT) {
+		return
+	}
+	go func() {
+		lockUI()
+		progressChangeWithToolTip("Process", 0)
+		audit := ValidateSitemap(searchedUrl, urlTree, progressChange)
+		log.Printf("Sitemap report: %s", fn)
+		if err := writeSitemapReport(fn, audit); err != nil {
+			log.Println("Unable to write sitemap report:", err)
+		}
+		message := fmt.Sprintf("Sitemap checked: %d URLs, %d findings", len(audit.Entries), len(audit.Findings))
+		progressChangeWithToolTip(message, 1)
+		glib.IdleAdd(func() {
+			applyTree(treeStore, urlTree)
+		})
+		unlockUI()
+	}()
+}
+
 var progressMtx sync.Mutex
 
 func progressChange(text string, progress float64) {
@@ -140,6 +314,10 @@ func lockUI() {
 		checkSingleDeepButton.SetSensitive(false)
 		saveButton.SetSensitive(false)
 		loadButton.SetSensitive(false)
+		reportButton.SetSensitive(false)
+		sitemapButton.SetSensitive(false)
+		sitemapExportButton.SetSensitive(false)
+		linkGraphButton.SetSensitive(false)
 		backButton.SetSensitive(false)
 	})
 }
@@ -154,6 +332,10 @@ func unlockUI() {
 		checkSingleDeepButton.SetSensitive(true)
 		saveButton.SetSensitive(true)
 		loadButton.SetSensitive(true)
+		reportButton.SetSensitive(true)
+		sitemapButton.SetSensitive(true)
+		sitemapExportButton.SetSensitive(true)
+		linkGraphButton.SetSensitive(true)
 		backButton.SetSensitive(true)
 	})
 }
@@ -194,7 +376,7 @@ func urlTreeSelectionChanged(s *gtk.TreeSelection) {
 				selectedUrlLink.SetLabel(uts.Url)
 			}
 			selectedUrlLink.SetSensitive(true)
-			applyList(listStore, &uts.InnerUrls)
+			refreshInnerList()
 		} else {
 			fmt.Println("No uts!")
 		}
@@ -203,6 +385,35 @@ func urlTreeSelectionChanged(s *gtk.TreeSelection) {
 	}
 }
 
+func refreshInnerList() {
+	if selectedUrl == nil {
+		listStore.Clear()
+		return
+	}
+	filter := strings.ToLower(entryText(innerUrlFilter))
+	view := innerUrlView.GetActiveID()
+	if view == INNER_VIEW_FINDINGS {
+		findings := make([]Finding, 0)
+		for _, f := range selectedUrl.Findings {
+			if strings.Contains(strings.ToLower(f.String()), filter) {
+				findings = append(findings, f)
+			}
+		}
+		applyFindings(listStore, findings)
+		return
+	}
+	list := make([]UrlStruct, 0)
+	for _, us := range selectedUrl.InnerUrls {
+		if view == INNER_VIEW_MIXED && us.MixedContent == MIXED_NONE {
+			continue
+		}
+		if strings.Contains(strings.ToLower(us.Url+" "+us.Context.Text), filter) {
+			list = append(list, us)
+		}
+	}
+	applyList(listStore, &list)
+}
+
 func startScanningProcess() {
 	clearSelection()
 	text, err := entry.GetText()
@@ -217,6 +428,10 @@ func startScanningProcess() {
 			lockUI()
 			message := "Process"
 			progressChangeWithToolTip(message, 0)
+			tlsAudit.Reset()
+			resetSoft404Probes()
+			resetImageProbes()
+			resetSizeProbes()
 			listOfUrls = StartScan(norm_url, progressChange)
 			urlTree = NewUrlTreeStruct(norm_url)
 			for _, page := range *listOfUrls {
@@ -224,6 +439,8 @@ func startScanningProcess() {
 				nts := NewUrlTreeStruct(page)
 				urlTree.AppendAccordingUrl(nts)
 			}
+			applyCrawlTraps(urlTree)
+			applyCrawlLinks(urlTree)
 			time2 := time.Now()
 			message = fmt.Sprintf("Process done at %s [%s]", time.Now().Format("15:04:05"), (time2.Sub(time1)))
 			progressChangeWithToolTip(message, 1)
@@ -293,39 +510,6 @@ func startCheckPagesFromSelected() {
 	}()
 }
 
-func settings(m string) {
-	dialog, _ := gtk.DialogNew()
-	dialog.SetTitle(m)
-	dialog.SetPosition(gtk.WIN_POS_CENTER)
-	dialog.SetDefaultSize(100, 100)
-
-	numEntry, _ := gtk.EntryNew()
-	// numEntry.Connect("insert-text", func(ctx *glib.CallbackContext) {
-	// 	a := (*[2000]uint8)(unsafe.Pointer(ctx.Args(0)))
-	// 	p := (*int)(unsafe.Pointer(ctx.Args(2)))
-	// 	i := 0
-	// 	for a[i] != 0 {
-	// 		i++
-	// 	}
-	// 	s := string(a[0:i])
-	// 	if s == "." {
-	// 		if *p == 0 {
-	// 			input.StopEmission("insert-text")
-	// 		}
-	// 	} else {
-	// 		_, err := strconv.Atof64(s)
-	// 		if err != nil {
-	// 			input.StopEmission("insert-text")
-	// 		}
-	// 	}
-	// })
-
-	dialog.Add(numEntry)
-
-	dialog.Run()
-	dialog.Destroy()
-}
-
 func main() {
 	fmt.Println("start-----------------")
 	gtk.Init(nil)
@@ -341,8 +525,11 @@ func main() {
 	not_allowed_pixbuf = getPixbuf("images/not_allowed.png")
 	tmr_pixbuf = getPixbuf("images/too_many_requests.png")
 	ise_pixbuf = getPixbuf("images/internal_server_error.png")
+	soft_not_found_pixbuf = getPixbuf("images/soft_not_found.png")
 	src_pixbuf = getPixbuf("images/img.png")
 	href_pixbuf = getPixbuf("images/link.png")
+	css_pixbuf = getPixbuf("images/css.png")
+	meta_pixbuf = getPixbuf("images/meta.png")
 
 	b, err := gtk.BuilderNew()
 	standartErrorHandle(err)
@@ -371,6 +558,16 @@ func main() {
 	standartErrorHandle(err)
 	sel.Connect("changed", urlTreeSelectionChanged)
 
+	obj, err = b.GetObject("UrlTreeFilter")
+	standartErrorHandle(err)
+	urlTreeFilter = obj.(*gtk.ComboBoxText)
+	urlTreeFilter.Connect("changed", func() {
+		if urlTree != nil {
+			clearSelection()
+			applyTree(treeStore, urlTree)
+		}
+	})
+
 	obj, err = b.GetObject("ProcessProgressBar")
 	standartErrorHandle(err)
 	progressBar = obj.(*gtk.ProgressBar)
@@ -410,7 +607,7 @@ func main() {
 	img.Show()
 	settingsButton.SetImage(img)
 	settingsButton.Connect("clicked", func() {
-		settings("Some title")
+		settings("Settings")
 	})
 
 	obj, err = b.GetObject("SaveButton")
@@ -427,6 +624,34 @@ func main() {
 		load()
 	})
 
+	obj, err = b.GetObject("ReportButton")
+	standartErrorHandle(err)
+	reportButton = obj.(*gtk.Button)
+	reportButton.Connect("clicked", func() {
+		exportReport()
+	})
+
+	obj, err = b.GetObject("SitemapButton")
+	standartErrorHandle(err)
+	sitemapButton = obj.(*gtk.Button)
+	sitemapButton.Connect("clicked", func() {
+		startSitemapValidation()
+	})
+
+	obj, err = b.GetObject("SitemapExportButton")
+	standartErrorHandle(err)
+	sitemapExportButton = obj.(*gtk.Button)
+	sitemapExportButton.Connect("clicked", func() {
+		exportSitemap()
+	})
+
+	obj, err = b.GetObject("LinkGraphButton")
+	standartErrorHandle(err)
+	linkGraphButton = obj.(*gtk.Button)
+	linkGraphButton.Connect("clicked", func() {
+		exportLinkGraph()
+	})
+
 	obj, err = b.GetObject("BackButton")
 	standartErrorHandle(err)
 	backButton = obj.(*gtk.Button)
@@ -442,6 +667,20 @@ func main() {
 	innerUrlTreeView = obj.(*gtk.TreeView)
 	listStore = setupTreeViewLikeList(innerUrlTreeView)
 
+	obj, err = b.GetObject("InnerUrlFilter")
+	standartErrorHandle(err)
+	innerUrlFilter = obj.(*gtk.Entry)
+	innerUrlFilter.Connect("changed", func() {
+		refreshInnerList()
+	})
+
+	obj, err = b.GetObject("InnerUrlView")
+	standartErrorHandle(err)
+	innerUrlView = obj.(*gtk.ComboBoxText)
+	innerUrlView.Connect("changed", func() {
+		refreshInnerList()
+	})
+
 	obj, err = b.GetObject("SelectedUrlLink")
 	standartErrorHandle(err)
 	selectedUrlLink = obj.(*gtk.LinkButton)
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	MIXED_NONE = iota
+	MIXED_PASSIVE
+	MIXED_ACTIVE
+)
+
+const (
+	RULE_MIXED_ACTIVE  = "mixed-content-active"
+	RULE_MIXED_PASSIVE = "mixed-content-passive"
+)
+
+type mixedElement struct {
+	selector string
+	attr     string
+	mixed    int
+	checked  bool
+}
+
+type mixedReference struct {
+	element *goquery.Selection
+	attr    string
+}
+
+var mixedElements = []mixedElement{
+	{"img[src]", "src", MIXED_PASSIVE, true},
+	{"audio[src]", "src", MIXED_PASSIVE, false},
+	{"video[src]", "src", MIXED_PASSIVE, true},
+	{"video[poster]", "poster", MIXED_PASSIVE, false},
+	{"source[src]", "src", MIXED_PASSIVE, false},
+	{"link[rel~=stylesheet][href]", "href", MIXED_ACTIVE, true},
+	{"script[src]", "src", MIXED_ACTIVE, true},
+	{"iframe[src]", "src", MIXED_ACTIVE, false},
+	{"frame[src]", "src", MIXED_ACTIVE, false},
+	{"object[data]", "data", MIXED_ACTIVE, false},
+	{"embed[src]", "src", MIXED_ACTIVE, false},
+}
+
+func mixedName(mixed int) string {
+	switch mixed {
+	case MIXED_PASSIVE:
+		return "passive mixed content"
+	case MIXED_ACTIVE:
+		return "active mixed content"
+	default:
+		return ""
+	}
+}
+
+func FindMixedContent(doc *goquery.Document, base nurl.URL) (map[string]int, map[string]mixedReference) {
+	mixed := make(map[string]int)
+	unchecked := make(map[string]mixedReference)
+	for _, element := range mixedElements {
+		el := element
+		doc.Find(el.selector).Each(func(i int, s *goquery.Selection) {
+			ref, _ := s.Attr(el.attr)
+			refUrl, err := base.Parse(ref)
+			if err != nil || refUrl.Scheme != "http" {
+				return
+			}
+			strRefUrl := refUrl.String()
+			if mixed[strRefUrl] < el.mixed {
+				mixed[strRefUrl] = el.mixed
+			}
+			if _, ok := unchecked[strRefUrl]; !ok && !el.checked {
+				unchecked[strRefUrl] = mixedReference{s, el.attr}
+			}
+		})
+	}
+	return mixed, unchecked
+}
+
+func checkMixedResources(unchecked map[string]mixedReference, source *PageSource, base nurl.URL, urlContainer *UrlTreeStruct, group *errgroup.Group) {
+	for ref, reference := range unchecked {
+		mixedRef := ref
+		context := source.Context(reference.element, reference.attr)
+		group.Go(func() error {
+			checkInnerUrl(base, mixedRef, urlContainer, INTENT_SRC, context)
+			return nil
+		})
+	}
+}
+
+func probeHttps(httpUrl string) string {
+	refUrl, err := nurl.Parse(httpUrl)
+	if err != nil {
+		return ""
+	}
+	refUrl.Scheme = "https"
+	client := newHttpClient(time.Second*10, limitRedirects)
+	resp, _, err := headRequest(client, refUrl.String(), nil)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return ""
+	}
+	return refUrl.String()
+}
+
+// markMixedContent flags insecure inner URLs and reports each of them once.
+// The https probes run without holding the inner URL lock.
+func markMixedContent(urlContainer *UrlTreeStruct, mixed map[string]int, probe bool) {
+	kinds := make(map[string]int)
+	urls := make([]string, 0)
+	urlContainer.innerMutex.Lock()
+	for i := range urlContainer.InnerUrls {
+		us := &urlContainer.InnerUrls[i]
+		kind, ok := mixed[us.Url]
+		if !ok && us.Intent == INTENT_CSS && us.LinkType != LINK_TYPE_FILE {
+			if refUrl, err := nurl.Parse(us.Url); err == nil && refUrl.Scheme == "http" {
+				kind = MIXED_PASSIVE
+			}
+		}
+		us.MixedContent = kind
+		if kind == MIXED_NONE {
+			continue
+		}
+		if known, ok := kinds[us.Url]; !ok {
+			urls = append(urls, us.Url)
+			kinds[us.Url] = kind
+		} else if kind > known {
+			kinds[us.Url] = kind
+		}
+	}
+	urlContainer.innerMutex.Unlock()
+
+	alternatives := make(map[string]string)
+	if probe {
+		var alternativesMtx sync.Mutex
+		group := new(errgroup.Group)
+		group.SetLimit(max_inner_pool)
+		for _, url := range urls {
+			target := url
+			group.Go(func() error {
+				if alternative := probeHttps(target); alternative != "" {
+					alternativesMtx.Lock()
+					alternatives[target] = alternative
+					alternativesMtx.Unlock()
+				}
+				return nil
+			})
+		}
+		group.Wait()
+
+		urlContainer.innerMutex.Lock()
+		for i := range urlContainer.InnerUrls {
+			us := &urlContainer.InnerUrls[i]
+			if us.MixedContent != MIXED_NONE {
+				us.HttpsAlternative = alternatives[us.Url]
+			}
+		}
+		urlContainer.innerMutex.Unlock()
+	}
+
+	for _, url := range urls {
+		finding := Finding{RULE_MIXED_PASSIVE, SEVERITY_WARNING, url, mixedName(kinds[url])}
+		if kinds[url] == MIXED_ACTIVE {
+			finding.Rule = RULE_MIXED_ACTIVE
+			finding.Severity = SEVERITY_ERROR
+		}
+		if alternatives[url] != "" {
+			finding.Message = fmt.Sprintf("%s, available as %s", finding.Message, alternatives[url])
+		}
+		urlContainer.AppendFinding(finding)
+	}
+}
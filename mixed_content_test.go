@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestMarkMixedContentReportsUrlOnce(t *testing.T) {
+	page := NewUrlTreeStruct("https://example.com/")
+	for i := 0; i < 3; i++ {
+		inner := NewUrlStruct("http://example.com/app.js")
+		inner.Intent = INTENT_SRC
+		page.AppendInnerUrl(inner)
+	}
+	markMixedContent(page, map[string]int{"http://example.com/app.js": MIXED_ACTIVE}, false)
+
+	if len(page.Findings) != 1 || page.Findings[0].Rule != RULE_MIXED_ACTIVE {
+		t.Fatalf("findings: %v", page.Findings)
+	}
+	for _, inner := range page.InnerUrls {
+		if inner.MixedContent != MIXED_ACTIVE {
+			t.Errorf("inner URL not marked: %+v", inner)
+		}
+	}
+}
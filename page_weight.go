@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	nurl "net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RESOURCE_OTHER = iota
+	RESOURCE_HTML
+	RESOURCE_IMAGE
+	RESOURCE_CSS
+	RESOURCE_SCRIPT
+	RESOURCE_FONT
+	RESOURCE_MEDIA
+)
+
+const (
+	RULE_PAGE_WEIGHT = "page-weight"
+
+	max_measured_size = 50 * 1024 * 1024
+)
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.count += int64(n)
+	return n, err
+}
+
+// wireBody decodes a response body requested with accept_encoding and counts
+// the bytes as they came over the wire, so all sizes share one unit.
+type wireBody struct {
+	wire    *countingReader
+	decoded io.Reader
+	closer  io.Closer
+}
+
+func newWireBody(resp *http.Response) (*wireBody, error) {
+	wire := &countingReader{reader: resp.Body}
+	body := &wireBody{wire: wire, decoded: wire, closer: resp.Body}
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		decoded, err := gzip.NewReader(wire)
+		if err == io.EOF {
+			return body, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		body.decoded = decoded
+	case "deflate":
+		buffered := bufio.NewReader(wire)
+		body.decoded = flate.NewReader(buffered)
+		// Most servers send deflate with the zlib header, some without.
+		if header, err := buffered.Peek(2); err == nil && header[0]&0x0f == 8 && (int(header[0])<<8|int(header[1]))%31 == 0 {
+			decoded, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, err
+			}
+			body.decoded = decoded
+		}
+	}
+	return body, nil
+}
+
+func (wb *wireBody) Read(p []byte) (int, error) {
+	return wb.decoded.Read(p)
+}
+
+func (wb *wireBody) Close() error {
+	return wb.closer.Close()
+}
+
+// Size is the number of bytes received so far.
+func (wb *wireBody) Size() int64 {
+	return wb.wire.count
+}
+
+func resourceTypeName(resourceType int) string {
+	switch resourceType {
+	case RESOURCE_HTML:
+		return "HTML"
+	case RESOURCE_IMAGE:
+		return "image"
+	case RESOURCE_CSS:
+		return "CSS"
+	case RESOURCE_SCRIPT:
+		return "script"
+	case RESOURCE_FONT:
+		return "font"
+	case RESOURCE_MEDIA:
+		return "media"
+	default:
+		return "other"
+	}
+}
+
+func mediaResourceType(contentType string) (int, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return RESOURCE_OTHER, false
+	}
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return RESOURCE_HTML, true
+	case strings.HasPrefix(mediaType, "image/"):
+		return RESOURCE_IMAGE, true
+	case mediaType == "text/css":
+		return RESOURCE_CSS, true
+	case strings.Contains(mediaType, "javascript") || mediaType == "application/ecmascript":
+		return RESOURCE_SCRIPT, true
+	case strings.HasPrefix(mediaType, "font/") || strings.Contains(mediaType, "font"):
+		return RESOURCE_FONT, true
+	case strings.HasPrefix(mediaType, "video/") || strings.HasPrefix(mediaType, "audio/"):
+		return RESOURCE_MEDIA, true
+	case strings.Contains(mediaType, "mpegurl") || mediaType == "application/dash+xml":
+		return RESOURCE_MEDIA, true
+	}
+	return RESOURCE_OTHER, false
+}
+
+func resourceTypeOf(contentType, url string) int {
+	if resourceType, ok := mediaResourceType(contentType); ok {
+		return resourceType
+	}
+	if refUrl, err := nurl.Parse(url); err == nil {
+		switch strings.ToLower(path.Ext(refUrl.Path)) {
+		case ".html", ".htm", ".php", ".aspx":
+			return RESOURCE_HTML
+		case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".svg", ".ico", ".bmp":
+			return RESOURCE_IMAGE
+		case ".css":
+			return RESOURCE_CSS
+		case ".js", ".mjs":
+			return RESOURCE_SCRIPT
+		case ".woff", ".woff2", ".ttf", ".otf", ".eot":
+			return RESOURCE_FONT
+		case ".mp4", ".webm", ".ogg", ".mp3", ".wav":
+			return RESOURCE_MEDIA
+		}
+	}
+	return RESOURCE_OTHER
+}
+
+type sizeProbe struct {
+	once sync.Once
+	size int64
+}
+
+var (
+	sizeProbes    = make(map[string]*sizeProbe)
+	sizeProbesMtx sync.Mutex
+)
+
+func resetSizeProbes() {
+	sizeProbesMtx.Lock()
+	sizeProbes = make(map[string]*sizeProbe)
+	sizeProbesMtx.Unlock()
+}
+
+// countsTowardWeight tells whether resources of the intent are loaded with the page.
+func countsTowardWeight(intent int) bool {
+	return intent == INTENT_SRC || intent == INTENT_CSS
+}
+
+// measureSize downloads a resource sent without Content-Length once per scan.
+// Images reuse the download made for the image size checks.
+func measureSize(url string, resourceType int) int64 {
+	if resourceType == RESOURCE_IMAGE {
+		info := imageInfo(url)
+		if info.Err != nil {
+			return -1
+		}
+		return info.Size
+	}
+	sizeProbesMtx.Lock()
+	probe, ok := sizeProbes[url]
+	if !ok {
+		probe = &sizeProbe{}
+		sizeProbes[url] = probe
+	}
+	sizeProbesMtx.Unlock()
+	probe.once.Do(func() {
+		probe.size = fetchSize(url)
+	})
+	return probe.size
+}
+
+func fetchSize(url string) int64 {
+	client := newHttpClient(time.Second*30, limitRedirects)
+	resp, wire, _, err := measuredGetRequest(client, url, nil)
+	if err != nil {
+		return -1
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, io.LimitReader(wire, max_measured_size)); err != nil {
+		return -1
+	}
+	return wire.Size()
+}
+
+type PageWeight struct {
+	Html    int64
+	Images  int64
+	Css     int64
+	Scripts int64
+	Fonts   int64
+	Other   int64
+	Unknown int
+}
+
+func (pw PageWeight) Total() int64 {
+	return pw.Html + pw.Images + pw.Css + pw.Scripts + pw.Fonts + pw.Other
+}
+
+func (pw PageWeight) String() string {
+	result := fmt.Sprintf("%s (HTML %s, images %s, CSS %s, scripts %s, fonts %s, other %s)",
+		formatSize(pw.Total()), formatSize(pw.Html), formatSize(pw.Images), formatSize(pw.Css),
+		formatSize(pw.Scripts), formatSize(pw.Fonts), formatSize(pw.Other))
+	if pw.Unknown > 0 {
+		result += fmt.Sprintf(", %d of unknown size", pw.Unknown)
+	}
+	return result
+}
+
+func (uts *UrlTreeStruct) PageWeight() PageWeight {
+	weight := PageWeight{Html: uts.PageSize}
+	counted := make(map[string]bool)
+	for _, us := range uts.InnerUrls {
+		if !countsTowardWeight(us.Intent) || us.Status != STATUS_SUCCESS || counted[us.Url] {
+			continue
+		}
+		counted[us.Url] = true
+		if us.SourceSize < 0 {
+			weight.Unknown++
+			continue
+		}
+		switch us.ResourceType {
+		case RESOURCE_IMAGE:
+			weight.Images += us.SourceSize
+		case RESOURCE_CSS:
+			weight.Css += us.SourceSize
+		case RESOURCE_SCRIPT:
+			weight.Scripts += us.SourceSize
+		case RESOURCE_FONT:
+			weight.Fonts += us.SourceSize
+		default:
+			weight.Other += us.SourceSize
+		}
+	}
+	return weight
+}
+
+func weightBudgetKiB(settings ScanSettings, pageUrl string) int {
+	budget := settings.DefaultWeightBudgetKiB
+	matched := ""
+	parsed, err := nurl.Parse(pageUrl)
+	if err != nil {
+		return budget
+	}
+	for _, rule := range settings.WeightBudgets {
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		prefix := strings.TrimSpace(parts[0])
+		value, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || !strings.HasPrefix(parsed.Path, prefix) || len(prefix) < len(matched) {
+			continue
+		}
+		matched = prefix
+		budget = value
+	}
+	return budget
+}
+
+func checkWeightBudget(uts *UrlTreeStruct, settings ScanSettings) {
+	budget := weightBudgetKiB(settings, uts.Url)
+	if budget <= 0 {
+		return
+	}
+	weight := uts.PageWeight()
+	if weight.Total() > int64(budget)*1024 {
+		uts.AppendFinding(Finding{RULE_PAGE_WEIGHT, SEVERITY_WARNING, uts.Url,
+			fmt.Sprintf("page weight %s exceeds budget of %d KiB", weight, budget)})
+	}
+}
+
+func fillResourceInfo(urlElement *UrlStruct, resp *http.Response) {
+	urlElement.ContentType = resp.Header.Get("Content-Type")
+	urlElement.ResourceType = resourceTypeOf(urlElement.ContentType, urlElement.Url)
+	urlElement.Cache = cacheInfoOf(resp)
+}
+
+type sizedAsset struct {
+	url  string
+	page string
+	size int64
+	kind int
+}
+
+func largestAssets(root *UrlTreeStruct, limit int) []sizedAsset {
+	seen := make(map[string]bool)
+	assets := make([]sizedAsset, 0)
+	root.Walk(func(uts *UrlTreeStruct) {
+		for _, us := range uts.InnerUrls {
+			if !countsTowardWeight(us.Intent) || us.SourceSize < 0 || seen[us.Url] {
+				continue
+			}
+			seen[us.Url] = true
+			assets = append(assets, sizedAsset{us.Url, uts.Url, us.SourceSize, us.ResourceType})
+		}
+	})
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].size > assets[j].size
+	})
+	if len(assets) > limit {
+		assets = assets[:limit]
+	}
+	return assets
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMeasureSizeDownloadsOnce(t *testing.T) {
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		w.Write([]byte("body { color: red }"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("\n"))
+	}))
+	defer server.Close()
+	resetSizeProbes()
+	defer resetSizeProbes()
+
+	for i := 0; i < 3; i++ {
+		if size := measureSize(server.URL+"/site.css", RESOURCE_CSS); size != 20 {
+			t.Fatalf("size: got %d, want 20", size)
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("downloads: got %d, want 1", downloads)
+	}
+}
+
+func TestCountsTowardWeight(t *testing.T) {
+	for intent, want := range map[int]bool{INTENT_HREF: false, INTENT_SRC: true, INTENT_CSS: true, INTENT_META: false} {
+		if got := countsTowardWeight(intent); got != want {
+			t.Errorf("intent %d: got %v, want %v", intent, got, want)
+		}
+	}
+}
+
+func compressed(t *testing.T, encoding, text string) []byte {
+	var buf bytes.Buffer
+	var writer io.WriteCloser = gzip.NewWriter(&buf)
+	if encoding == "deflate" {
+		writer = zlib.NewWriter(&buf)
+	}
+	if _, err := writer.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	return buf.Bytes()
+}
+
+func TestMeasuredGetCountsTransferredBytes(t *testing.T) {
+	text := strings.Repeat("body { color: red }\n", 200)
+	for _, encoding := range []string{"gzip", "deflate"} {
+		body := compressed(t, encoding, text)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+				w.Write([]byte(text))
+				return
+			}
+			w.Header().Set("Content-Encoding", encoding)
+			w.Write(body[:10])
+			w.(http.Flusher).Flush()
+			w.Write(body[10:])
+		}))
+
+		resp, wire, _, err := measuredGetRequest(server.Client(), server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := io.ReadAll(wire)
+		resp.Body.Close()
+		if err != nil || string(decoded) != text {
+			t.Errorf("%s: decoded %d bytes, err %v", encoding, len(decoded), err)
+		}
+		if wire.Size() != int64(len(body)) {
+			t.Errorf("%s: size %d, want %d", encoding, wire.Size(), len(body))
+		}
+		server.Close()
+	}
+}
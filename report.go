@@ -0,0 +1,433 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func writeReportHeader(sb *strings.Builder, title string) {
+	fmt.Fprintf(sb, "\n== %s ==\n", title)
+}
+
+func writeFindings(sb *strings.Builder, findings []Finding) {
+	if len(findings) == 0 {
+		sb.WriteString("No findings\n")
+		return
+	}
+	for _, f := range findings {
+		sb.WriteString(f.String())
+		sb.WriteString("\n")
+	}
+}
+
+func writeTlsReport(sb *strings.Builder, audit *TlsAudit, expiryDays int, now time.Time) {
+	writeReportHeader(sb, "TLS certificates")
+	for _, info := range audit.SortedHosts() {
+		fmt.Fprintf(sb, "%s %s\n", info.Host, info.Version)
+		for _, cert := range info.Chain {
+			fmt.Fprintf(sb, "  %s issued by %s, expires %s\n", cert.Subject, cert.Issuer, cert.NotAfter.Format("2006-01-02"))
+			if len(cert.SANs) > 0 {
+				fmt.Fprintf(sb, "    SANs: %s\n", strings.Join(cert.SANs, ", "))
+			}
+		}
+	}
+	writeReportHeader(sb, "TLS and HTTPS warnings")
+	writeFindings(sb, audit.Findings(expiryDays, now))
+}
+
+func writeFailureReport(sb *strings.Builder, root *UrlTreeStruct) {
+	writeReportHeader(sb, "Network failures")
+	byFailure := make(map[int][]string)
+	root.Walk(func(uts *UrlTreeStruct) {
+		if uts.Failure != FAILURE_NONE {
+			byFailure[uts.Failure] = append(byFailure[uts.Failure], fmt.Sprintf("%s: %s", uts.Url, uts.FailureMessage))
+		}
+		for _, us := range uts.InnerUrls {
+			if us.Failure != FAILURE_NONE {
+				location := uts.Url
+				if context := us.Context.Location(); context != "" {
+					location += ", " + context
+				}
+				byFailure[us.Failure] = append(byFailure[us.Failure],
+					fmt.Sprintf("%s (on %s): %s", us.Url, location, us.FailureMessage))
+			}
+		}
+	})
+	if len(byFailure) == 0 {
+		sb.WriteString("No failures\n")
+		return
+	}
+	for failure := FAILURE_TIMEOUT; failure <= FAILURE_OTHER; failure++ {
+		entries := byFailure[failure]
+		if len(entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(sb, "%s: %d\n", failureName(failure), len(entries))
+		sort.Strings(entries)
+		for _, entry := range entries {
+			fmt.Fprintf(sb, "  %s\n", entry)
+		}
+	}
+}
+
+func writeCrawlTrapReport(sb *strings.Builder, root *UrlTreeStruct) {
+	writeReportHeader(sb, "Suspected crawl traps")
+	found := false
+	root.Walk(func(uts *UrlTreeStruct) {
+		if uts.Trap != "" {
+			found = true
+			fmt.Fprintf(sb, "%s: %s\n", uts.Url, uts.Trap)
+		}
+	})
+	if !found {
+		sb.WriteString("None\n")
+	}
+}
+
+func writeDuplicateReport(sb *strings.Builder, root *UrlTreeStruct) {
+	writeReportHeader(sb, "Duplicate content")
+	groups := DuplicateGroups(root)
+	if len(groups) == 0 {
+		sb.WriteString("None\n")
+		return
+	}
+	for _, group := range groups {
+		fmt.Fprintf(sb, "%s (%d pages):\n", duplicateName(group.Kind), len(group.Urls))
+		for _, url := range group.Urls {
+			fmt.Fprintf(sb, "  %s\n", url)
+		}
+	}
+}
+
+func writePageFindingsReport(sb *strings.Builder, root *UrlTreeStruct) {
+	writeReportHeader(sb, "Page findings")
+	found := false
+	root.Walk(func(uts *UrlTreeStruct) {
+		if len(uts.Findings) == 0 {
+			return
+		}
+		found = true
+		findings := append([]Finding(nil), uts.Findings...)
+		sortFindings(findings)
+		fmt.Fprintf(sb, "%s\n", uts.Url)
+		for _, f := range findings {
+			fmt.Fprintf(sb, "  %s\n", f)
+		}
+	})
+	if !found {
+		sb.WriteString("No findings\n")
+	}
+}
+
+type timedUrl struct {
+	url    string
+	page   string
+	timing RequestTiming
+}
+
+func writeTimedUrls(sb *strings.Builder, list []timedUrl) {
+	if len(list) == 0 {
+		sb.WriteString("Nothing above threshold\n")
+		return
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].timing.Total > list[j].timing.Total
+	})
+	for _, tu := range list {
+		if tu.page != "" {
+			fmt.Fprintf(sb, "%d ms %s (on %s)\n  %s\n", durationMs(tu.timing.Total), tu.url, tu.page, tu.timing)
+		} else {
+			fmt.Fprintf(sb, "%d ms %s\n  %s\n", durationMs(tu.timing.Total), tu.url, tu.timing)
+		}
+	}
+}
+
+func writeTimingReport(sb *strings.Builder, root *UrlTreeStruct, slowPageMs, slowResourceMs int) {
+	slowPages := make([]timedUrl, 0)
+	slowResources := make([]timedUrl, 0)
+	root.Walk(func(uts *UrlTreeStruct) {
+		if durationMs(uts.Timing.Total) >= slowPageMs {
+			slowPages = append(slowPages, timedUrl{uts.Url, "", uts.Timing})
+		}
+		for _, us := range uts.InnerUrls {
+			if us.Intent != INTENT_HREF && durationMs(us.Timing.Total) >= slowResourceMs {
+				slowResources = append(slowResources, timedUrl{us.Url, uts.Url, us.Timing})
+			}
+		}
+	})
+	writeReportHeader(sb, fmt.Sprintf("Slow pages (>= %d ms)", slowPageMs))
+	writeTimedUrls(sb, slowPages)
+	writeReportHeader(sb, fmt.Sprintf("Slow resources (>= %d ms)", slowResourceMs))
+	writeTimedUrls(sb, slowResources)
+}
+
+func writeWeightReport(sb *strings.Builder, root *UrlTreeStruct) {
+	type weightedPage struct {
+		url    string
+		weight PageWeight
+	}
+	pages := make([]weightedPage, 0)
+	root.Walk(func(uts *UrlTreeStruct) {
+		if uts.PageSize > 0 {
+			pages = append(pages, weightedPage{uts.Url, uts.PageWeight()})
+		}
+	})
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].weight.Total() > pages[j].weight.Total()
+	})
+	writeReportHeader(sb, "Pages by weight")
+	if len(pages) == 0 {
+		sb.WriteString("No checked pages\n")
+	}
+	for _, page := range pages {
+		fmt.Fprintf(sb, "%s\n  %s\n", page.url, page.weight)
+	}
+	writeReportHeader(sb, "Largest assets")
+	for _, asset := range largestAssets(root, 25) {
+		fmt.Fprintf(sb, "%s %s %s (on %s)\n", formatSize(asset.size), resourceTypeName(asset.kind), asset.url, asset.page)
+	}
+}
+
+func writeLinkReport(sb *strings.Builder, root *UrlTreeStruct, maxDepth int) {
+	pages := make([]*UrlTreeStruct, 0)
+	byDepth := make(map[int]int)
+	root.Walk(func(uts *UrlTreeStruct) {
+		pages = append(pages, uts)
+		byDepth[uts.Metrics.Depth]++
+	})
+	writeReportHeader(sb, "Click depth")
+	depths := make([]int, 0, len(byDepth))
+	for depth := range byDepth {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+	for _, depth := range depths {
+		if depth == DEPTH_UNREACHABLE {
+			fmt.Fprintf(sb, "not reachable: %d pages\n", byDepth[depth])
+		} else {
+			fmt.Fprintf(sb, "%d clicks: %d pages\n", depth, byDepth[depth])
+		}
+	}
+	views := []struct {
+		view, title string
+	}{
+		{TREE_VIEW_DEEP, fmt.Sprintf("Pages deeper than %d clicks", maxDepth)},
+		{TREE_VIEW_ORPHAN, "Pages not reachable by links"},
+		{TREE_VIEW_WEAK, "Pages linked from one page only"},
+	}
+	for _, v := range views {
+		writeReportHeader(sb, v.title)
+		found := false
+		for _, uts := range pages {
+			if treeViewMatches(v.view, uts, maxDepth) {
+				found = true
+				fmt.Fprintf(sb, "%s\n", uts.Url)
+			}
+		}
+		if !found {
+			sb.WriteString("None\n")
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].Metrics.PageRank > pages[j].Metrics.PageRank
+	})
+	writeReportHeader(sb, "Pages by PageRank")
+	sb.WriteString("score\tdepth\tinlinks\tpages\turl\n")
+	for _, uts := range pages {
+		fmt.Fprintf(sb, "%d\t%d\t%d\t%d\t%s\n", uts.Metrics.Score, uts.Metrics.Depth,
+			uts.Metrics.InboundLinks, uts.Metrics.LinkingPages, uts.Url)
+	}
+}
+
+func writeRuleSummary(sb *strings.Builder, root *UrlTreeStruct, prefix string) {
+	pagesByRule := make(map[string]map[string]bool)
+	root.Walk(func(uts *UrlTreeStruct) {
+		for _, f := range uts.Findings {
+			if !strings.HasPrefix(f.Rule, prefix) {
+				continue
+			}
+			if pagesByRule[f.Rule] == nil {
+				pagesByRule[f.Rule] = make(map[string]bool)
+			}
+			pagesByRule[f.Rule][uts.Url] = true
+		}
+	})
+	rules := make([]string, 0, len(pagesByRule))
+	for rule := range pagesByRule {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	if len(rules) == 0 {
+		sb.WriteString("No findings\n")
+	}
+	for _, rule := range rules {
+		fmt.Fprintf(sb, "%s: %d pages\n", rule, len(pagesByRule[rule]))
+	}
+}
+
+func writeSeoReport(sb *strings.Builder, root *UrlTreeStruct) {
+	writeReportHeader(sb, "SEO summary")
+	writeRuleSummary(sb, root, "seo-")
+	writeReportHeader(sb, "SEO site findings")
+	writeFindings(sb, SeoSiteFindings(root))
+}
+
+func writeHostSummary(sb *strings.Builder, summary []hostRuleCount, unit string) {
+	if len(summary) == 0 {
+		sb.WriteString("No findings\n")
+	}
+	host := ""
+	for _, entry := range summary {
+		if entry.host != host {
+			host = entry.host
+			fmt.Fprintf(sb, "%s\n", host)
+		}
+		fmt.Fprintf(sb, "  %s: %d %s\n", entry.rule, entry.urls, unit)
+	}
+}
+
+func writeSecurityReport(sb *strings.Builder, root *UrlTreeStruct) {
+	writeReportHeader(sb, "Security headers by host")
+	writeHostSummary(sb, SecurityHostSummary(root), "pages")
+}
+
+func writeCachingReport(sb *strings.Builder, root *UrlTreeStruct) {
+	writeReportHeader(sb, "Caching and compression by host")
+	writeHostSummary(sb, CachingHostSummary(root), "assets")
+}
+
+func writeStructuredDataReport(sb *strings.Builder, root *UrlTreeStruct) {
+	writeReportHeader(sb, "Structured data")
+	pagesByType := make(map[string]int)
+	missing := make([]string, 0)
+	root.Walk(func(uts *UrlTreeStruct) {
+		for _, t := range uts.Seo.StructuredTypes {
+			pagesByType[t]++
+		}
+		if uts.Status == STATUS_SUCCESS && len(uts.Seo.StructuredTypes) == 0 {
+			missing = append(missing, uts.Url)
+		}
+	})
+	types := make([]string, 0, len(pagesByType))
+	for t := range pagesByType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Fprintf(sb, "%s: %d pages\n", t, pagesByType[t])
+	}
+	writeRuleSummary(sb, root, "structured-data-")
+	writeReportHeader(sb, "Pages without structured data")
+	if len(missing) == 0 {
+		sb.WriteString("None\n")
+	}
+	sort.Strings(missing)
+	for _, url := range missing {
+		fmt.Fprintf(sb, "%s\n", url)
+	}
+}
+
+func writeHreflangReport(sb *strings.Builder, root *UrlTreeStruct) {
+	writeReportHeader(sb, "hreflang clusters")
+	clusters := HreflangClusters(root)
+	if len(clusters) == 0 {
+		sb.WriteString("No hreflang annotations\n")
+		return
+	}
+	findings := collectFindings(root, "hreflang-")
+	sortFindings(findings)
+	for i, cluster := range clusters {
+		fmt.Fprintf(sb, "Cluster %d (%d URLs)\n", i+1, len(cluster.Urls))
+		for _, url := range cluster.Urls {
+			links, crawled := cluster.Pages[url]
+			if !crawled {
+				fmt.Fprintf(sb, "  %s (no annotations found)\n", url)
+				continue
+			}
+			langs := make([]string, 0, len(links))
+			for _, link := range links {
+				langs = append(langs, link.Lang)
+			}
+			fmt.Fprintf(sb, "  %s: %s\n", url, strings.Join(langs, ", "))
+		}
+		for _, f := range findings {
+			if _, ok := cluster.Pages[f.Url]; ok {
+				fmt.Fprintf(sb, "  %s\n", f)
+			}
+		}
+	}
+}
+
+func buildReport(root *UrlTreeStruct) string {
+	settings := currentSettings()
+	now := time.Now()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Site Scanner report for %s\nGenerated %s\n", root.Url, now.Format("2006-01-02 15:04:05"))
+	writeTlsReport(&sb, tlsAudit, settings.CertExpiryDays, now)
+	writeFailureReport(&sb, root)
+	writeCrawlTrapReport(&sb, root)
+	writeSecurityReport(&sb, root)
+	writeSeoReport(&sb, root)
+	writeStructuredDataReport(&sb, root)
+	writeHreflangReport(&sb, root)
+	writeDuplicateReport(&sb, root)
+	writeLinkReport(&sb, root, settings.MaxClickDepth)
+	writeReportHeader(&sb, "Social cards summary")
+	writeRuleSummary(&sb, root, "social-")
+	if settings.AccessibilityChecks {
+		writeReportHeader(&sb, "Accessibility summary")
+		writeRuleSummary(&sb, root, "a11y-")
+	}
+	if settings.ImageSizeChecks {
+		writeReportHeader(&sb, "Image size summary")
+		writeRuleSummary(&sb, root, "image-")
+	}
+	writeTimingReport(&sb, root, settings.SlowPageMs, settings.SlowResourceMs)
+	writeWeightReport(&sb, root)
+	writeCachingReport(&sb, root)
+	writePageFindingsReport(&sb, root)
+	return sb.String()
+}
+
+func buildSitemapReport(audit *SitemapAudit) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Sitemap validation report\nGenerated %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	writeReportHeader(&sb, "Sitemaps")
+	for _, sitemap := range audit.Sitemaps {
+		fmt.Fprintf(&sb, "%s\n", sitemap)
+	}
+	fmt.Fprintf(&sb, "%d URLs listed\n", len(audit.Entries))
+	sections := []struct {
+		rule  string
+		title string
+	}{
+		{RULE_SITEMAP_UNREADABLE, "Unreadable sitemaps"},
+		{RULE_SITEMAP_STATUS, "Sitemap URLs that do not return 200"},
+		{RULE_SITEMAP_NOINDEX, "Sitemap URLs marked noindex"},
+		{RULE_SITEMAP_CANONICAL, "Sitemap URLs canonicalised elsewhere"},
+		{RULE_SITEMAP_MISSING, "Crawled pages missing from sitemap"},
+		{RULE_SITEMAP_ORPHAN, "Orphan sitemap entries"},
+	}
+	for _, section := range sections {
+		writeReportHeader(&sb, section.title)
+		findings := make([]Finding, 0)
+		for _, f := range audit.Findings {
+			if f.Rule == section.rule {
+				findings = append(findings, f)
+			}
+		}
+		writeFindings(&sb, findings)
+	}
+	return sb.String()
+}
+
+func writeSitemapReport(filePath string, audit *SitemapAudit) error {
+	return os.WriteFile(filePath, []byte(buildSitemapReport(audit)), 0644)
+}
+
+func writeReport(filePath string, root *UrlTreeStruct) error {
+	return os.WriteFile(filePath, []byte(buildReport(root)), 0644)
+}
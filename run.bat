@@ -1,2 +1,2 @@
-go run main.go treeview_control.go url_tree_struct.go site_scanner.go check_url.go serialization.go -gcflags=all="-N"
+go run main.go treeview_control.go url_tree_struct.go site_scanner.go check_url.go serialization.go settings.go transport.go findings.go tls_audit.go report.go failures.go css_links.go mixed_content.go soft404.go timing.go page_weight.go seo_audit.go accessibility_audit.go security_headers.go caching_audit.go content_type.go image_size.go structured_data.go hreflang.go social_meta.go sitemap.go crawl_traps.go duplicate_content.go link_metrics.go link_graph.go link_context.go -gcflags=all="-N"
 pause
\ No newline at end of file
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	RULE_SEC_HSTS            = "security-hsts"
+	RULE_SEC_CSP             = "security-csp"
+	RULE_SEC_CONTENT_TYPE    = "security-content-type-options"
+	RULE_SEC_FRAME           = "security-frame-options"
+	RULE_SEC_REFERRER        = "security-referrer-policy"
+	RULE_SEC_COOKIE_SECURE   = "security-cookie-secure"
+	RULE_SEC_COOKIE_HTTPONLY = "security-cookie-httponly"
+	RULE_SEC_COOKIE_SAMESITE = "security-cookie-samesite"
+
+	hsts_min_max_age = 180 * 24 * 60 * 60
+)
+
+func cspDirectives(policy string) map[string]string {
+	directives := make(map[string]string)
+	for _, directive := range strings.Split(policy, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if _, ok := directives[name]; !ok {
+			directives[name] = strings.ToLower(strings.Join(fields[1:], " "))
+		}
+	}
+	return directives
+}
+
+func hstsMaxAge(value string) (int, bool) {
+	for _, directive := range strings.Split(value, ";") {
+		parts := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "max-age") {
+			maxAge, err := strconv.Atoi(strings.Trim(parts[1], `"`))
+			return maxAge, err == nil
+		}
+	}
+	return 0, false
+}
+
+func AuditSecurityHeaders(resp *http.Response, pageUrl string) []Finding {
+	findings := make([]Finding, 0)
+	add := func(rule string, severity int, message string) {
+		findings = append(findings, Finding{rule, severity, pageUrl, message})
+	}
+	header := resp.Header
+	isHttps := resp.Request != nil && resp.Request.URL.Scheme == "https"
+
+	if isHttps {
+		if hsts := header.Get("Strict-Transport-Security"); hsts == "" {
+			add(RULE_SEC_HSTS, SEVERITY_WARNING, "Strict-Transport-Security is missing")
+		} else if maxAge, ok := hstsMaxAge(hsts); !ok || maxAge < hsts_min_max_age {
+			add(RULE_SEC_HSTS, SEVERITY_WARNING, fmt.Sprintf("Strict-Transport-Security max-age is too short: %q", hsts))
+		}
+	}
+
+	csp := header.Get("Content-Security-Policy")
+	directives := cspDirectives(csp)
+	if csp == "" {
+		add(RULE_SEC_CSP, SEVERITY_WARNING, "Content-Security-Policy is missing")
+	} else {
+		scriptSrc, ok := directives["script-src"]
+		if !ok {
+			scriptSrc, ok = directives["default-src"]
+		}
+		switch {
+		case !ok:
+			add(RULE_SEC_CSP, SEVERITY_WARNING, "Content-Security-Policy has neither script-src nor default-src")
+		case strings.Contains(scriptSrc, "'unsafe-inline'") || strings.Contains(scriptSrc, "'unsafe-eval'"):
+			add(RULE_SEC_CSP, SEVERITY_WARNING, fmt.Sprintf("Content-Security-Policy allows unsafe scripts: %q", scriptSrc))
+		case strings.Contains(" "+scriptSrc+" ", " * "):
+			add(RULE_SEC_CSP, SEVERITY_WARNING, "Content-Security-Policy allows scripts from any origin")
+		}
+	}
+
+	if value := header.Get("X-Content-Type-Options"); !strings.EqualFold(strings.TrimSpace(value), "nosniff") {
+		add(RULE_SEC_CONTENT_TYPE, SEVERITY_WARNING, "X-Content-Type-Options: nosniff is missing")
+	}
+
+	frameOptions := strings.ToUpper(strings.TrimSpace(header.Get("X-Frame-Options")))
+	_, hasFrameAncestors := directives["frame-ancestors"]
+	if !hasFrameAncestors {
+		switch frameOptions {
+		case "DENY", "SAMEORIGIN":
+		case "":
+			add(RULE_SEC_FRAME, SEVERITY_WARNING, "neither X-Frame-Options nor CSP frame-ancestors is set")
+		default:
+			add(RULE_SEC_FRAME, SEVERITY_WARNING, fmt.Sprintf("X-Frame-Options %q is not supported by browsers", frameOptions))
+		}
+	}
+
+	referrer := strings.ToLower(header.Get("Referrer-Policy"))
+	switch {
+	case referrer == "":
+		add(RULE_SEC_REFERRER, SEVERITY_INFO, "Referrer-Policy is missing")
+	case strings.Contains(referrer, "unsafe-url") || strings.Contains(referrer, "no-referrer-when-downgrade"):
+		add(RULE_SEC_REFERRER, SEVERITY_WARNING, fmt.Sprintf("Referrer-Policy %q leaks full URLs", referrer))
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if !cookie.Secure && isHttps {
+			add(RULE_SEC_COOKIE_SECURE, SEVERITY_WARNING, fmt.Sprintf("cookie %s is set without Secure", cookie.Name))
+		}
+		if !cookie.HttpOnly {
+			add(RULE_SEC_COOKIE_HTTPONLY, SEVERITY_INFO, fmt.Sprintf("cookie %s is set without HttpOnly", cookie.Name))
+		}
+		if cookie.SameSite == 0 {
+			add(RULE_SEC_COOKIE_SAMESITE, SEVERITY_INFO, fmt.Sprintf("cookie %s is set without SameSite", cookie.Name))
+		} else if cookie.SameSite == http.SameSiteNoneMode && !cookie.Secure {
+			add(RULE_SEC_COOKIE_SAMESITE, SEVERITY_WARNING, fmt.Sprintf("cookie %s is SameSite=None without Secure", cookie.Name))
+		}
+	}
+	sortFindings(findings)
+	return findings
+}
+
+func SecurityHostSummary(root *UrlTreeStruct) []hostRuleCount {
+	return summariseByHost(collectFindings(root, "security-"))
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	nurl "net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	RULE_SEO_TITLE_MISSING      = "seo-title-missing"
+	RULE_SEO_TITLE_MULTIPLE     = "seo-title-multiple"
+	RULE_SEO_TITLE_LENGTH       = "seo-title-length"
+	RULE_SEO_TITLE_DUPLICATE    = "seo-title-duplicate"
+	RULE_SEO_DESC_MISSING       = "seo-description-missing"
+	RULE_SEO_DESC_MULTIPLE      = "seo-description-multiple"
+	RULE_SEO_DESC_DUPLICATE     = "seo-description-duplicate"
+	RULE_SEO_H1_MISSING         = "seo-h1-missing"
+	RULE_SEO_H1_MULTIPLE        = "seo-h1-multiple"
+	RULE_SEO_HEADING_ORDER      = "seo-heading-order"
+	RULE_SEO_NOINDEX_NAVIGATION = "seo-noindex-in-navigation"
+
+	seo_title_min = 10
+	seo_title_max = 60
+)
+
+type SeoInfo struct {
+	Title       string
+	Description string
+	Noindex     bool
+	Canonical   string
+	NavLinks    []string
+	Hreflang    []HreflangLink
+
+	StructuredTypes []string
+}
+
+func isNoindex(doc *goquery.Document, header http.Header) bool {
+	for _, value := range header.Values("X-Robots-Tag") {
+		if strings.Contains(strings.ToLower(value), "noindex") {
+			return true
+		}
+	}
+	noindex := false
+	doc.Find("meta[name]").Each(func(i int, meta *goquery.Selection) {
+		name, _ := meta.Attr("name")
+		content, _ := meta.Attr("content")
+		name = strings.ToLower(name)
+		if (name == "robots" || name == "googlebot") && strings.Contains(strings.ToLower(content), "noindex") {
+			noindex = true
+		}
+	})
+	return noindex
+}
+
+func canonicalUrl(doc *goquery.Document, base nurl.URL) string {
+	canonical := ""
+	doc.Find("link[rel][href]").EachWithBreak(func(i int, link *goquery.Selection) bool {
+		if !strings.EqualFold(strings.TrimSpace(link.AttrOr("rel", "")), "canonical") {
+			return true
+		}
+		if target, err := base.Parse(link.AttrOr("href", "")); err == nil {
+			canonical = target.String()
+		}
+		return false
+	})
+	return canonical
+}
+
+func navigationLinks(doc *goquery.Document, base nurl.URL) []string {
+	found := make(map[string]bool)
+	links := make([]string, 0)
+	doc.Find("nav a[href], header a[href], [role=navigation] a[href]").Each(func(i int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		hrefUrl, err := base.Parse(href)
+		if err != nil || (hrefUrl.Scheme != "http" && hrefUrl.Scheme != "https") {
+			return
+		}
+		hrefUrl.Fragment = ""
+		hrefUrl.RawQuery = ""
+		norm, err := Normalize(hrefUrl.String())
+		if err != nil || found[norm] {
+			return
+		}
+		found[norm] = true
+		links = append(links, norm)
+	})
+	return links
+}
+
+func AuditSeo(doc *goquery.Document, header http.Header, pageUrl string, base nurl.URL) (SeoInfo, []Finding) {
+	findings := make([]Finding, 0)
+	add := func(rule string, severity int, message string) {
+		findings = append(findings, Finding{rule, severity, pageUrl, message})
+	}
+	info := SeoInfo{
+		Noindex:   isNoindex(doc, header),
+		Canonical: canonicalUrl(doc, base),
+		NavLinks:  navigationLinks(doc, base),
+		Hreflang:  hreflangLinks(doc, base),
+	}
+
+	titles := doc.Find("title").FilterFunction(func(i int, s *goquery.Selection) bool {
+		return s.ParentsFiltered("svg").Length() == 0
+	})
+	switch titles.Length() {
+	case 0:
+		add(RULE_SEO_TITLE_MISSING, SEVERITY_ERROR, "page has no <title>")
+	default:
+		if titles.Length() > 1 {
+			add(RULE_SEO_TITLE_MULTIPLE, SEVERITY_WARNING, fmt.Sprintf("page has %d <title> elements", titles.Length()))
+		}
+		info.Title = strings.Join(strings.Fields(titles.First().Text()), " ")
+		length := utf8.RuneCountInString(info.Title)
+		if length == 0 {
+			add(RULE_SEO_TITLE_MISSING, SEVERITY_ERROR, "<title> is empty")
+		} else if length < seo_title_min || length > seo_title_max {
+			add(RULE_SEO_TITLE_LENGTH, SEVERITY_WARNING,
+				fmt.Sprintf("title is %d characters long, expected %d-%d", length, seo_title_min, seo_title_max))
+		}
+	}
+
+	descriptions := doc.Find("meta[name]").FilterFunction(func(i int, s *goquery.Selection) bool {
+		name, _ := s.Attr("name")
+		return strings.EqualFold(name, "description")
+	})
+	switch descriptions.Length() {
+	case 0:
+		add(RULE_SEO_DESC_MISSING, SEVERITY_WARNING, "page has no meta description")
+	default:
+		if descriptions.Length() > 1 {
+			add(RULE_SEO_DESC_MULTIPLE, SEVERITY_WARNING, fmt.Sprintf("page has %d meta descriptions", descriptions.Length()))
+		}
+		content, _ := descriptions.First().Attr("content")
+		info.Description = strings.Join(strings.Fields(content), " ")
+		if info.Description == "" {
+			add(RULE_SEO_DESC_MISSING, SEVERITY_WARNING, "meta description is empty")
+		}
+	}
+
+	switch h1Count := doc.Find("h1").Length(); {
+	case h1Count == 0:
+		add(RULE_SEO_H1_MISSING, SEVERITY_WARNING, "page has no <h1>")
+	case h1Count > 1:
+		add(RULE_SEO_H1_MULTIPLE, SEVERITY_WARNING, fmt.Sprintf("page has %d <h1> elements", h1Count))
+	}
+
+	previous := 0
+	doc.Find("h1, h2, h3, h4, h5, h6").EachWithBreak(func(i int, heading *goquery.Selection) bool {
+		level := int(goquery.NodeName(heading)[1] - '0')
+		if previous > 0 && level > previous+1 {
+			add(RULE_SEO_HEADING_ORDER, SEVERITY_INFO,
+				fmt.Sprintf("<h%d> %q follows <h%d>", level, strings.TrimSpace(heading.Text()), previous))
+			return false
+		}
+		previous = level
+		return true
+	})
+	return info, findings
+}
+
+func groupPagesBy(root *UrlTreeStruct, key func(*UrlTreeStruct) string) map[string][]string {
+	groups := make(map[string][]string)
+	root.Walk(func(uts *UrlTreeStruct) {
+		if value := key(uts); value != "" {
+			groups[value] = append(groups[value], uts.Url)
+		}
+	})
+	for value, urls := range groups {
+		if len(urls) < 2 {
+			delete(groups, value)
+		}
+	}
+	return groups
+}
+
+func SeoSiteFindings(root *UrlTreeStruct) []Finding {
+	findings := make([]Finding, 0)
+	for title, urls := range groupPagesBy(root, func(uts *UrlTreeStruct) string { return uts.Seo.Title }) {
+		sort.Strings(urls)
+		for _, url := range urls {
+			findings = append(findings, Finding{RULE_SEO_TITLE_DUPLICATE, SEVERITY_WARNING, url,
+				fmt.Sprintf("title %q is shared by %d pages", title, len(urls))})
+		}
+	}
+	for description, urls := range groupPagesBy(root, func(uts *UrlTreeStruct) string { return uts.Seo.Description }) {
+		sort.Strings(urls)
+		for _, url := range urls {
+			findings = append(findings, Finding{RULE_SEO_DESC_DUPLICATE, SEVERITY_WARNING, url,
+				fmt.Sprintf("meta description %q is shared by %d pages", description, len(urls))})
+		}
+	}
+
+	noindex := make(map[string]bool)
+	root.Walk(func(uts *UrlTreeStruct) {
+		if uts.Seo.Noindex {
+			noindex[uts.Url] = true
+		}
+	})
+	root.Walk(func(uts *UrlTreeStruct) {
+		for _, link := range uts.Seo.NavLinks {
+			if noindex[link] {
+				findings = append(findings, Finding{RULE_SEO_NOINDEX_NAVIGATION, SEVERITY_WARNING, link,
+					fmt.Sprintf("noindex page is linked from navigation of %s", uts.Url)})
+			}
+		}
+	})
+	sortFindings(findings)
+	return findings
+}
+
+var seoSiteRules = []string{RULE_SEO_TITLE_DUPLICATE, RULE_SEO_DESC_DUPLICATE, RULE_SEO_NOINDEX_NAVIGATION}
+
+// applySiteFindings replaces the findings of the given site-level rules on
+// every page, so repeated checks do not pile them up.
+func applySiteFindings(root *UrlTreeStruct, rules []string, findings []Finding) {
+	pages := make(map[string]*UrlTreeStruct)
+	root.Walk(func(uts *UrlTreeStruct) {
+		pages[uts.Url] = uts
+		uts.RemoveFindings(rules)
+	})
+	for _, f := range findings {
+		if uts, ok := pages[f.Url]; ok {
+			uts.AppendFinding(f)
+		}
+	}
+}
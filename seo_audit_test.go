@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestApplySiteFindingsReplacesRules(t *testing.T) {
+	root := NewUrlTreeStruct("https://example.com/")
+	page := NewUrlTreeStruct("https://example.com/a")
+	root.AppendChild(page)
+	root.Seo.Title = "Same"
+	page.Seo.Title = "Same"
+	page.AppendFinding(Finding{RULE_SEO_H1_MISSING, SEVERITY_WARNING, page.Url, "page has no h1"})
+
+	for i := 0; i < 2; i++ {
+		applySiteFindings(root, seoSiteRules, SeoSiteFindings(root))
+	}
+	if len(page.Findings) != 2 || len(root.Findings) != 1 {
+		t.Fatalf("findings after two checks: %v and %v", root.Findings, page.Findings)
+	}
+
+	page.ResetAudit()
+	applySiteFindings(root, seoSiteRules, SeoSiteFindings(root))
+	if len(root.Findings) != 0 || len(page.Findings) != 0 {
+		t.Errorf("stale findings after reset: %v and %v", root.Findings, page.Findings)
+	}
+}
@@ -24,3 +24,29 @@ func readGob(filePath string, object interface{}) error {
 	file.Close()
 	return err
 }
+
+type ProjectCard struct {
+	Settings ScanSettings
+	Pages    []UrlTreeStructCard
+	Tls      *TlsAudit
+}
+
+func writeProject(filePath string, project *ProjectCard) error {
+	return writeGob(filePath, project)
+}
+
+func readProject(filePath string) (*ProjectCard, error) {
+	project := new(ProjectCard)
+	err := readGob(filePath, project)
+	if err == nil {
+		if project.Tls == nil {
+			project.Tls = NewTlsAudit()
+		}
+		return project, nil
+	}
+	var pages []UrlTreeStructCard
+	if legacyErr := readGob(filePath, &pages); legacyErr != nil {
+		return nil, err
+	}
+	return &ProjectCard{Settings: NewScanSettings(), Pages: pages, Tls: NewTlsAudit()}, nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+type NetworkSettings struct {
+	ProxyUrl        string
+	NoProxy         []string
+	CaFiles         []string
+	ClientCertFile  string
+	ClientKeyFile   string
+	IgnoreTlsErrors bool
+	HostOverrides   []string
+}
+
+type ScanSettings struct {
+	Network         NetworkSettings
+	CertExpiryDays  int
+	ProbeMixedHttps bool
+
+	Soft404TitlePattern string
+	Soft404TextPattern  string
+	soft404Title        *regexp.Regexp
+	soft404Text         *regexp.Regexp
+
+	SlowPageMs     int
+	SlowResourceMs int
+
+	DefaultWeightBudgetKiB int
+	WeightBudgets          []string
+
+	AccessibilityChecks bool
+
+	ImageSizeChecks bool
+	MaxImageKiB     int
+
+	SitemapLastmod bool
+
+	MaxClickDepth int
+}
+
+func NewScanSettings() ScanSettings {
+	return ScanSettings{
+		CertExpiryDays:         30,
+		SlowPageMs:             2000,
+		SlowResourceMs:         1000,
+		DefaultWeightBudgetKiB: 2048,
+		MaxImageKiB:            300,
+		MaxClickDepth:          3,
+	}
+}
+
+var (
+	scanSettings    = NewScanSettings()
+	scanSettingsMtx sync.Mutex
+)
+
+func currentSettings() ScanSettings {
+	scanSettingsMtx.Lock()
+	defer scanSettingsMtx.Unlock()
+	return scanSettings
+}
+
+func applySettings(newSettings ScanSettings) error {
+	var err error
+	if newSettings.soft404Title, err = compilePattern(newSettings.Soft404TitlePattern); err != nil {
+		return fmt.Errorf("soft 404 title pattern: %w", err)
+	}
+	if newSettings.soft404Text, err = compilePattern(newSettings.Soft404TextPattern); err != nil {
+		return fmt.Errorf("soft 404 text pattern: %w", err)
+	}
+	transport, err := newTransport(newSettings.Network)
+	if err != nil {
+		return err
+	}
+	scanSettingsMtx.Lock()
+	scanSettings = newSettings
+	scanSettingsMtx.Unlock()
+	setSharedTransport(transport)
+	return nil
+}
+
+// compilePattern returns nil for an empty pattern, which matches nothing.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+func splitList(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' '
+	})
+}
+
+func joinList(list []string) string {
+	return strings.Join(list, ", ")
+}
+
+func newSettingsEntry(text string) *gtk.Entry {
+	entry, err := gtk.EntryNew()
+	if err != nil {
+		log.Fatal("Unable to create entry:", err)
+	}
+	entry.SetText(text)
+	entry.SetHExpand(true)
+	return entry
+}
+
+func newSettingsCheck(label string, active bool) *gtk.CheckButton {
+	check, err := gtk.CheckButtonNewWithLabel(label)
+	if err != nil {
+		log.Fatal("Unable to create check button:", err)
+	}
+	check.SetActive(active)
+	return check
+}
+
+func attachSettingsRow(grid *gtk.Grid, row int, title string, widget gtk.IWidget) {
+	label, err := gtk.LabelNew(title)
+	if err != nil {
+		log.Fatal("Unable to create label:", err)
+	}
+	label.SetXAlign(0)
+	grid.Attach(label, 0, row, 1, 1)
+	grid.Attach(widget, 1, row, 1, 1)
+}
+
+func entryText(entry *gtk.Entry) string {
+	text, err := entry.GetText()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(text)
+}
+
+func entryInt(entry *gtk.Entry, fallback int) int {
+	value, err := strconv.Atoi(entryText(entry))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+func settings(m string) {
+	current := currentSettings()
+
+	dialog, _ := gtk.DialogNew()
+	dialog.SetTitle(m)
+	dialog.SetPosition(gtk.WIN_POS_CENTER)
+	dialog.SetDefaultSize(450, 100)
+	dialog.AddButton("Cancel", gtk.RESPONSE_CANCEL)
+	dialog.AddButton("Apply", gtk.RESPONSE_ACCEPT)
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(5)
+	grid.SetColumnSpacing(5)
+	grid.SetMarginStart(5)
+	grid.SetMarginEnd(5)
+	grid.SetMarginTop(5)
+	grid.SetMarginBottom(5)
+
+	proxyEntry := newSettingsEntry(current.Network.ProxyUrl)
+	proxyEntry.SetPlaceholderText("http://host:port or socks5://host:port")
+	attachSettingsRow(grid, 0, "Proxy", proxyEntry)
+	noProxyEntry := newSettingsEntry(joinList(current.Network.NoProxy))
+	noProxyEntry.SetPlaceholderText("localhost, .corp.example.com")
+	attachSettingsRow(grid, 1, "No proxy for", noProxyEntry)
+	caEntry := newSettingsEntry(joinList(current.Network.CaFiles))
+	caEntry.SetPlaceholderText("PEM bundles")
+	attachSettingsRow(grid, 2, "Trusted CA files", caEntry)
+	certEntry := newSettingsEntry(current.Network.ClientCertFile)
+	attachSettingsRow(grid, 3, "Client certificate", certEntry)
+	keyEntry := newSettingsEntry(current.Network.ClientKeyFile)
+	attachSettingsRow(grid, 4, "Client key", keyEntry)
+	insecureCheck := newSettingsCheck("Ignore TLS errors (results are marked)", current.Network.IgnoreTlsErrors)
+	attachSettingsRow(grid, 5, "", insecureCheck)
+	resolveEntry := newSettingsEntry(joinList(current.Network.HostOverrides))
+	resolveEntry.SetPlaceholderText("www.example.com:443:10.0.0.5")
+	attachSettingsRow(grid, 6, "Resolve host", resolveEntry)
+	expiryEntry := newSettingsEntry(strconv.Itoa(current.CertExpiryDays))
+	attachSettingsRow(grid, 7, "Warn about certificates expiring in (days)", expiryEntry)
+	probeCheck := newSettingsCheck("Probe HTTPS versions of mixed content", current.ProbeMixedHttps)
+	attachSettingsRow(grid, 8, "", probeCheck)
+	soft404TitleEntry := newSettingsEntry(current.Soft404TitlePattern)
+	soft404TitleEntry.SetPlaceholderText("regular expression")
+	attachSettingsRow(grid, 9, "Soft 404 title pattern", soft404TitleEntry)
+	soft404TextEntry := newSettingsEntry(current.Soft404TextPattern)
+	soft404TextEntry.SetPlaceholderText("regular expression")
+	attachSettingsRow(grid, 10, "Soft 404 text pattern", soft404TextEntry)
+	slowPageEntry := newSettingsEntry(strconv.Itoa(current.SlowPageMs))
+	attachSettingsRow(grid, 11, "Slow page threshold (ms)", slowPageEntry)
+	slowResourceEntry := newSettingsEntry(strconv.Itoa(current.SlowResourceMs))
+	attachSettingsRow(grid, 12, "Slow resource threshold (ms)", slowResourceEntry)
+	weightBudgetEntry := newSettingsEntry(strconv.Itoa(current.DefaultWeightBudgetKiB))
+	attachSettingsRow(grid, 13, "Page weight budget (KiB, 0 = off)", weightBudgetEntry)
+	weightBudgetsEntry := newSettingsEntry(joinList(current.WeightBudgets))
+	weightBudgetsEntry.SetPlaceholderText("/blog/=1500, /products/=3000")
+	attachSettingsRow(grid, 14, "Budgets by path prefix (KiB)", weightBudgetsEntry)
+	accessibilityCheck := newSettingsCheck("Run accessibility checks", current.AccessibilityChecks)
+	attachSettingsRow(grid, 15, "", accessibilityCheck)
+	imageSizeCheck := newSettingsCheck("Download images and check their dimensions", current.ImageSizeChecks)
+	attachSettingsRow(grid, 16, "", imageSizeCheck)
+	maxImageEntry := newSettingsEntry(strconv.Itoa(current.MaxImageKiB))
+	attachSettingsRow(grid, 17, "Image size limit (KiB, 0 = off)", maxImageEntry)
+	sitemapLastmodCheck := newSettingsCheck("Write lastmod from Last-Modified into generated sitemaps", current.SitemapLastmod)
+	attachSettingsRow(grid, 18, "", sitemapLastmodCheck)
+	maxClickDepthEntry := newSettingsEntry(strconv.Itoa(current.MaxClickDepth))
+	attachSettingsRow(grid, 19, "Maximum click depth (0 = off)", maxClickDepthEntry)
+
+	area, _ := dialog.GetContentArea()
+	area.Add(grid)
+	dialog.ShowAll()
+
+	if dialog.Run() == gtk.RESPONSE_ACCEPT {
+		newSettings := current
+		newSettings.Network = NetworkSettings{
+			ProxyUrl:        entryText(proxyEntry),
+			NoProxy:         splitList(entryText(noProxyEntry)),
+			CaFiles:         splitList(entryText(caEntry)),
+			ClientCertFile:  entryText(certEntry),
+			ClientKeyFile:   entryText(keyEntry),
+			IgnoreTlsErrors: insecureCheck.GetActive(),
+			HostOverrides:   splitList(entryText(resolveEntry)),
+		}
+		newSettings.CertExpiryDays = entryInt(expiryEntry, current.CertExpiryDays)
+		newSettings.ProbeMixedHttps = probeCheck.GetActive()
+		newSettings.Soft404TitlePattern = entryText(soft404TitleEntry)
+		newSettings.Soft404TextPattern = entryText(soft404TextEntry)
+		newSettings.SlowPageMs = entryInt(slowPageEntry, current.SlowPageMs)
+		newSettings.SlowResourceMs = entryInt(slowResourceEntry, current.SlowResourceMs)
+		newSettings.DefaultWeightBudgetKiB = entryInt(weightBudgetEntry, current.DefaultWeightBudgetKiB)
+		newSettings.WeightBudgets = splitList(entryText(weightBudgetsEntry))
+		newSettings.AccessibilityChecks = accessibilityCheck.GetActive()
+		newSettings.ImageSizeChecks = imageSizeCheck.GetActive()
+		newSettings.MaxImageKiB = entryInt(maxImageEntry, current.MaxImageKiB)
+		newSettings.SitemapLastmod = sitemapLastmodCheck.GetActive()
+		newSettings.MaxClickDepth = entryInt(maxClickDepthEntry, current.MaxClickDepth)
+		if err := applySettings(newSettings); err != nil {
+			log.Println("settings not applied:", err)
+			progressChangeWithToolTip(fmt.Sprintf("Settings not applied: %s", err), 0)
+		}
+	}
+	dialog.Destroy()
+}
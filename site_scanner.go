@@ -59,14 +59,18 @@ func Normalize(url string) (string, error) {
 	return norm_url, nil
 }
 
+func pageKey(url string) string {
+	if norm, err := Normalize(url); err == nil {
+		return norm
+	}
+	return url
+}
+
 func StartScan(norm_url string, progress func(string, float64)) *[]string {
 
-	client := &http.Client{
-		CheckRedirect: func(r *http.Request, via []*http.Request) error {
-			r.URL.Opaque = r.URL.Path
-			return nil
-		},
-	}
+	client := newHttpClient(0, keepOpaquePath)
+	resetCrawlTraps()
+	resetCrawlLinks()
 
 	var pages = map[string]int{}
 	var counter = &CounterUnit{Count: 0}
@@ -122,7 +126,7 @@ func StartScan(norm_url string, progress func(string, float64)) *[]string {
 func scanNextPage(client *http.Client, host string, pages *map[string]int, index int, counter *CounterUnit, progress func(string, float64)) {
 
 	get_url, ok := SafeGetByValue(pages, index)
-	if !ok {
+	if !ok || isCrawlTrap(get_url) {
 		return
 	}
 	norm_url, err := nlzurl.Normalize(get_url)
@@ -142,6 +146,7 @@ func scanNextPage(client *http.Client, host string, pages *map[string]int, index
 	if err != nil {
 		return
 	}
+	defer resp.Body.Close()
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
@@ -155,6 +160,11 @@ func scanNextPage(client *http.Client, host string, pages *map[string]int, index
 			//clear := href
 			href_url, err := base.Parse(href)
 			if err == nil {
+				if strings.Contains(href_url.String(), host) {
+					if page, err := Normalize(href_url.Scheme + "://" + href_url.Host + href_url.Path); err == nil {
+						countQueryVariant(page, href_url.RawQuery)
+					}
+				}
 				href_url.RawQuery = ""
 				href_url.Fragment = ""
 				href, err = nurl.QueryUnescape(href_url.String())
@@ -165,6 +175,9 @@ func scanNextPage(client *http.Client, host string, pages *map[string]int, index
 					//fmt.Println("Clear/Dirt", clear, "/", href)
 					fileExtension := filepath.Ext(href)
 					if len(fileExtension) == 0 {
+						if target, err := Normalize(href); err == nil {
+							recordCrawlLink(get_url, strings.ReplaceAll(target, "\\", "/"), anchorText(a))
+						}
 						addAllCombinatons(host, href, pages, counter)
 					}
 				}
@@ -196,10 +209,17 @@ func addAllCombinatons(host, href string, pages *map[string]int, counter *Counte
 		if err != nil {
 			log.Println("normalize err ", err)
 		}
+		reason := crawlTrapReason(norm_url)
+		if reason != "" {
+			markCrawlTrap(norm_url, reason)
+		}
 		mtx.Lock()
 		if _, ok := (*pages)[norm_url]; !ok {
 			(*pages)[norm_url] = counter.NextValue()
 		}
 		mtx.Unlock()
+		if reason != "" {
+			break
+		}
 	}
 }
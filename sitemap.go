@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	nurl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SITEMAP_UNCHECKED = iota
+	SITEMAP_LISTED
+	SITEMAP_MISSING
+	SITEMAP_CONFLICT
+)
+
+const (
+	RULE_SITEMAP_UNREADABLE = "sitemap-unreadable"
+	RULE_SITEMAP_STATUS     = "sitemap-bad-status"
+	RULE_SITEMAP_NOINDEX    = "sitemap-noindex"
+	RULE_SITEMAP_CANONICAL  = "sitemap-canonicalised"
+	RULE_SITEMAP_MISSING    = "sitemap-missing-page"
+	RULE_SITEMAP_ORPHAN     = "sitemap-orphan"
+
+	max_sitemap_files = 100
+	max_sitemap_size  = 50 * 1024 * 1024
+	max_sitemap_urls  = 50000
+	sitemap_xmlns     = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapDocument struct {
+	XMLName  xml.Name
+	Urls     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapUrlset struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Urls    []sitemapLoc `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type SitemapAudit struct {
+	Sitemaps []string
+	Entries  []string
+	Findings []Finding
+}
+
+func sitemapName(state int) string {
+	switch state {
+	case SITEMAP_LISTED:
+		return "listed in sitemap"
+	case SITEMAP_MISSING:
+		return "missing from sitemap"
+	case SITEMAP_CONFLICT:
+		return "listed in sitemap, but should not be"
+	default:
+		return ""
+	}
+}
+
+func sitemapMark(state int) string {
+	switch state {
+	case SITEMAP_MISSING:
+		return "[no sitemap] "
+	case SITEMAP_CONFLICT:
+		return "[sitemap conflict] "
+	default:
+		return ""
+	}
+}
+
+func discoverSitemaps(siteUrl nurl.URL) []string {
+	sitemaps := make([]string, 0)
+	robotsUrl, _ := siteUrl.Parse("/robots.txt")
+	client := newHttpClient(time.Second*20, limitRedirects)
+	resp, _, err := getRequest(client, robotsUrl.String(), nil)
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 200 {
+			scanner := bufio.NewScanner(io.LimitReader(resp.Body, max_sitemap_size))
+			for scanner.Scan() {
+				parts := strings.SplitN(scanner.Text(), ":", 2)
+				if len(parts) == 2 && strings.EqualFold(strings.TrimSpace(parts[0]), "sitemap") {
+					sitemaps = append(sitemaps, strings.TrimSpace(parts[1]))
+				}
+			}
+		}
+	}
+	if len(sitemaps) == 0 {
+		defaultUrl, _ := siteUrl.Parse("/sitemap.xml")
+		sitemaps = append(sitemaps, defaultUrl.String())
+	}
+	return sitemaps
+}
+
+func fetchSitemap(url string) (*sitemapDocument, error) {
+	client := newHttpClient(time.Second*60, limitRedirects)
+	resp, _, err := getRequest(client, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	body := bufio.NewReader(resp.Body)
+	var reader io.Reader = body
+	if magic, err := body.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	document := &sitemapDocument{}
+	if err := xml.NewDecoder(io.LimitReader(reader, max_sitemap_size)).Decode(document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+func ValidateSitemap(siteUrl string, root *UrlTreeStruct, progress func(string, float64)) *SitemapAudit {
+	audit := &SitemapAudit{Sitemaps: make([]string, 0), Entries: make([]string, 0), Findings: make([]Finding, 0)}
+	add := func(rule string, severity int, url, message string) {
+		audit.Findings = append(audit.Findings, Finding{rule, severity, url, message})
+	}
+	base, err := nurl.Parse(siteUrl)
+	if err != nil {
+		add(RULE_SITEMAP_UNREADABLE, SEVERITY_ERROR, siteUrl, err.Error())
+		return audit
+	}
+
+	queue := discoverSitemaps(*base)
+	seen := make(map[string]bool)
+	listed := make(map[string]bool)
+	for len(queue) > 0 && len(audit.Sitemaps) < max_sitemap_files {
+		sitemapUrl := queue[0]
+		queue = queue[1:]
+		if seen[sitemapUrl] {
+			continue
+		}
+		seen[sitemapUrl] = true
+		audit.Sitemaps = append(audit.Sitemaps, sitemapUrl)
+		progress(fmt.Sprintf("Read sitemap %s", sitemapUrl), 0)
+		document, err := fetchSitemap(sitemapUrl)
+		if err != nil {
+			add(RULE_SITEMAP_UNREADABLE, SEVERITY_ERROR, sitemapUrl, err.Error())
+			continue
+		}
+		for _, sitemap := range document.Sitemaps {
+			queue = append(queue, strings.TrimSpace(sitemap.Loc))
+		}
+		for _, entry := range document.Urls {
+			loc := strings.TrimSpace(entry.Loc)
+			if loc != "" && !listed[loc] {
+				listed[loc] = true
+				audit.Entries = append(audit.Entries, loc)
+			}
+		}
+	}
+	sort.Strings(audit.Entries)
+
+	progress(fmt.Sprintf("Check %d sitemap URLs", len(audit.Entries)), 0.5)
+	problems := probeDirectStatus(audit.Entries)
+
+	pages := make(map[string]*UrlTreeStruct)
+	root.Walk(func(uts *UrlTreeStruct) {
+		uts.Sitemap = SITEMAP_UNCHECKED
+		pages[uts.Url] = uts
+	})
+	for _, loc := range audit.Entries {
+		uts, crawled := pages[pageKey(loc)]
+		conflict := false
+		if problem, ok := problems[loc]; ok {
+			add(RULE_SITEMAP_STATUS, SEVERITY_ERROR, loc, problem)
+			conflict = true
+		}
+		if !crawled {
+			add(RULE_SITEMAP_ORPHAN, SEVERITY_WARNING, loc, "listed in sitemap, but not reachable by crawling")
+			continue
+		}
+		if uts.Seo.Noindex {
+			add(RULE_SITEMAP_NOINDEX, SEVERITY_ERROR, loc, "listed in sitemap, but marked noindex")
+			conflict = true
+		}
+		if uts.Seo.Canonical != "" && pageKey(uts.Seo.Canonical) != uts.Url {
+			add(RULE_SITEMAP_CANONICAL, SEVERITY_WARNING, loc, fmt.Sprintf("listed in sitemap, but canonical is %s", uts.Seo.Canonical))
+			conflict = true
+		}
+		uts.Sitemap = SITEMAP_LISTED
+		if conflict {
+			uts.Sitemap = SITEMAP_CONFLICT
+		}
+	}
+	root.Walk(func(uts *UrlTreeStruct) {
+		if uts.Sitemap != SITEMAP_UNCHECKED || uts.Status != STATUS_SUCCESS || uts.Seo.Noindex {
+			return
+		}
+		if uts.Seo.Canonical != "" && pageKey(uts.Seo.Canonical) != uts.Url {
+			return
+		}
+		uts.Sitemap = SITEMAP_MISSING
+		add(RULE_SITEMAP_MISSING, SEVERITY_WARNING, uts.Url, "crawled page is not listed in sitemap")
+	})
+	sortFindings(audit.Findings)
+	return audit
+}
+
+func sitemapLastmod(lastModified string) string {
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return ""
+	}
+	return modified.UTC().Format(time.RFC3339)
+}
+
+func SitemapLocs(root *UrlTreeStruct, listOfUrls []string, withLastmod bool) []sitemapLoc {
+	pages := make(map[string]*UrlTreeStruct)
+	root.Walk(func(uts *UrlTreeStruct) {
+		pages[uts.Url] = uts
+	})
+	locs := make([]sitemapLoc, 0, len(listOfUrls)+1)
+	for _, url := range append([]string{root.Url}, listOfUrls...) {
+		uts, ok := pages[url]
+		if !ok || uts.Status != STATUS_SUCCESS || uts.Seo.Noindex {
+			continue
+		}
+		if uts.Seo.Canonical != "" && pageKey(uts.Seo.Canonical) != uts.Url {
+			continue
+		}
+		loc := sitemapLoc{Loc: url}
+		if parsed, err := nurl.Parse(url); err == nil {
+			loc.Loc = parsed.String()
+		}
+		if withLastmod {
+			loc.LastMod = sitemapLastmod(uts.LastModified)
+		}
+		locs = append(locs, loc)
+	}
+	return locs
+}
+
+func writeXmlFile(filePath string, document interface{}) error {
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+// WriteSitemap writes locs to filePath. Above max_sitemap_urls the entries are split
+// into numbered files next to filePath, and filePath becomes their sitemap index.
+func WriteSitemap(filePath, siteUrl string, locs []sitemapLoc) ([]string, error) {
+	if len(locs) <= max_sitemap_urls {
+		return []string{filePath}, writeXmlFile(filePath, sitemapUrlset{Xmlns: sitemap_xmlns, Urls: locs})
+	}
+	base, err := nurl.Parse(siteUrl)
+	if err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(filePath)
+	stem := strings.TrimSuffix(filepath.Base(filePath), ext)
+	index := sitemapIndex{Xmlns: sitemap_xmlns}
+	written := make([]string, 0)
+	for part := 0; part*max_sitemap_urls < len(locs); part++ {
+		end := (part + 1) * max_sitemap_urls
+		if end > len(locs) {
+			end = len(locs)
+		}
+		name := fmt.Sprintf("%s-%d%s", stem, part+1, ext)
+		partPath := filepath.Join(filepath.Dir(filePath), name)
+		if err := writeXmlFile(partPath, sitemapUrlset{Xmlns: sitemap_xmlns, Urls: locs[part*max_sitemap_urls : end]}); err != nil {
+			return written, err
+		}
+		written = append(written, partPath)
+		partUrl, _ := base.Parse("/" + name)
+		index.Sitemaps = append(index.Sitemaps, sitemapLoc{Loc: partUrl.String()})
+	}
+	if err := writeXmlFile(filePath, index); err != nil {
+		return written, err
+	}
+	return append([]string{filePath}, written...), nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	nurl "net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	RULE_SOCIAL_OG_MISSING   = "social-og-missing"
+	RULE_SOCIAL_OG_RELATIVE  = "social-og-relative-url"
+	RULE_SOCIAL_TWITTER_CARD = "social-twitter-card"
+	RULE_SOCIAL_TWITTER_TAGS = "social-twitter-missing"
+	RULE_SOCIAL_IMAGE        = "social-image-broken"
+)
+
+var (
+	requiredOpenGraph = []string{"og:title", "og:type", "og:image", "og:url"}
+	twitterCards      = map[string]bool{"summary": true, "summary_large_image": true, "app": true, "player": true}
+	socialImageTags   = []string{"og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src"}
+)
+
+func socialTags(doc *goquery.Document) map[string]string {
+	tags := make(map[string]string)
+	doc.Find("meta[content]").Each(func(i int, meta *goquery.Selection) {
+		name := meta.AttrOr("property", "")
+		if name == "" {
+			name = meta.AttrOr("name", "")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !strings.HasPrefix(name, "og:") && !strings.HasPrefix(name, "twitter:") {
+			return
+		}
+		if _, ok := tags[name]; !ok {
+			tags[name] = strings.TrimSpace(meta.AttrOr("content", ""))
+		}
+	})
+	return tags
+}
+
+func AuditSocialTags(doc *goquery.Document, pageUrl string) []Finding {
+	findings := make([]Finding, 0)
+	add := func(rule string, severity int, message string) {
+		findings = append(findings, Finding{rule, severity, pageUrl, message})
+	}
+	tags := socialTags(doc)
+
+	missing := make([]string, 0)
+	for _, name := range requiredOpenGraph {
+		if tags[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		add(RULE_SOCIAL_OG_MISSING, SEVERITY_WARNING, fmt.Sprintf("Open Graph tags missing: %s", strings.Join(missing, ", ")))
+	}
+	for _, name := range []string{"og:url", "og:image"} {
+		if value := tags[name]; value != "" {
+			if parsed, err := nurl.Parse(value); err != nil || !parsed.IsAbs() {
+				add(RULE_SOCIAL_OG_RELATIVE, SEVERITY_WARNING, fmt.Sprintf("%s must be an absolute URL: %q", name, value))
+			}
+		}
+	}
+
+	card := tags["twitter:card"]
+	switch {
+	case card == "":
+		add(RULE_SOCIAL_TWITTER_CARD, SEVERITY_WARNING, "twitter:card is missing")
+	case !twitterCards[card]:
+		add(RULE_SOCIAL_TWITTER_CARD, SEVERITY_ERROR, fmt.Sprintf("twitter:card %q is not a known card type", card))
+	}
+	fallbacks := map[string]string{"twitter:title": "og:title", "twitter:description": "og:description"}
+	if card == "summary_large_image" {
+		fallbacks["twitter:image"] = "og:image"
+	}
+	missing = missing[:0]
+	for name, fallback := range fallbacks {
+		if tags[name] == "" && tags[fallback] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if card != "" && len(missing) > 0 {
+		sort.Strings(missing)
+		add(RULE_SOCIAL_TWITTER_TAGS, SEVERITY_WARNING,
+			fmt.Sprintf("twitter card has neither %s nor their Open Graph fallbacks", strings.Join(missing, ", ")))
+	}
+	return findings
+}
+
+func checkSocialImages(doc *goquery.Document, source *PageSource, base nurl.URL, urlContainer *UrlTreeStruct, group *errgroup.Group) {
+	tags := socialTags(doc)
+	checked := make(map[string]bool)
+	for _, name := range socialImageTags {
+		image := tags[name]
+		if image == "" || checked[image] {
+			continue
+		}
+		checked[image] = true
+		meta := doc.Find(fmt.Sprintf(`meta[property="%s"][content], meta[name="%s"][content]`, name, name)).First()
+		context := source.Context(meta, "content")
+		group.Go(func() error {
+			checkInnerUrl(base, image, urlContainer, INTENT_META, context)
+			return nil
+		})
+	}
+}
+
+func markSocialImages(urlContainer *UrlTreeStruct) {
+	urlContainer.innerMutex.Lock()
+	defer urlContainer.innerMutex.Unlock()
+
+	for _, us := range urlContainer.InnerUrls {
+		if us.Intent != INTENT_META || us.Status == STATUS_SUCCESS {
+			continue
+		}
+		problem := fmt.Sprintf("status %d", us.Status)
+		if us.Failure != FAILURE_NONE {
+			problem = failureName(us.Failure) + ": " + us.FailureMessage
+		}
+		urlContainer.AppendFinding(Finding{RULE_SOCIAL_IMAGE, SEVERITY_ERROR, us.Url,
+			fmt.Sprintf("social preview image is not available (%s)", problem)})
+	}
+}
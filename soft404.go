@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	nurl "net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	RULE_SOFT404 = "soft-404"
+
+	soft404_shingle         = 3
+	soft404_title_similar   = 0.8
+	soft404_content_similar = 0.95
+)
+
+type PageFingerprint struct {
+	Title    string
+	Shingles map[string]bool
+}
+
+type soft404Probe struct {
+	once        sync.Once
+	fingerprint *PageFingerprint
+}
+
+var (
+	soft404Probes    = make(map[string]*soft404Probe)
+	soft404ProbesMtx sync.Mutex
+	wordRegexp       = regexp.MustCompile(`[\p{L}\p{N}]+`)
+)
+
+func resetSoft404Probes() {
+	soft404ProbesMtx.Lock()
+	soft404Probes = make(map[string]*soft404Probe)
+	soft404ProbesMtx.Unlock()
+}
+
+func pageTitle(doc *goquery.Document) string {
+	return strings.TrimSpace(doc.Find("title").First().Text())
+}
+
+func pageText(doc *goquery.Document) string {
+	body := doc.Find("body").Clone()
+	body.Find("script, style, noscript").Remove()
+	return body.Text()
+}
+
+func textWords(text string) []string {
+	return wordRegexp.FindAllString(strings.ToLower(text), -1)
+}
+
+func wordShingles(words []string, size int) map[string]bool {
+	shingles := make(map[string]bool)
+	if len(words) < size {
+		if len(words) > 0 {
+			shingles[strings.Join(words, " ")] = true
+		}
+		return shingles
+	}
+	for i := 0; i+size <= len(words); i++ {
+		shingles[strings.Join(words[i:i+size], " ")] = true
+	}
+	return shingles
+}
+
+func NewPageFingerprint(doc *goquery.Document, pageUrl nurl.URL) *PageFingerprint {
+	pathWords := make(map[string]bool)
+	for _, word := range textWords(pageUrl.Path) {
+		pathWords[word] = true
+	}
+	words := make([]string, 0)
+	for _, word := range textWords(pageText(doc)) {
+		if !pathWords[word] {
+			words = append(words, word)
+		}
+	}
+	return &PageFingerprint{
+		Title:    pageTitle(doc),
+		Shingles: wordShingles(words, soft404_shingle),
+	}
+}
+
+func (pf *PageFingerprint) Similarity(other *PageFingerprint) float64 {
+	if len(pf.Shingles) == 0 && len(other.Shingles) == 0 {
+		return 1
+	}
+	common := 0
+	for shingle := range pf.Shingles {
+		if other.Shingles[shingle] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(pf.Shingles)+len(other.Shingles)-common)
+}
+
+func randomNotFoundUrl(pageUrl nurl.URL) nurl.URL {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return nurl.URL{Scheme: pageUrl.Scheme, Host: pageUrl.Host, Path: "/" + hex.EncodeToString(buf) + "/"}
+}
+
+func fetchNotFoundFingerprint(pageUrl nurl.URL) *PageFingerprint {
+	notFoundUrl := randomNotFoundUrl(pageUrl)
+	// A redirect of unknown paths leads to a real page, most often the home
+	// page, which must not be taken for the error page.
+	client := newHttpClient(time.Second*10, noRedirects)
+	resp, _, err := getRequest(client, notFoundUrl.String(), nil)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil
+	}
+	return NewPageFingerprint(doc, notFoundUrl)
+}
+
+func hostNotFoundFingerprint(pageUrl nurl.URL) *PageFingerprint {
+	key := pageUrl.Scheme + "://" + pageUrl.Host
+	soft404ProbesMtx.Lock()
+	probe, ok := soft404Probes[key]
+	if !ok {
+		probe = &soft404Probe{}
+		soft404Probes[key] = probe
+	}
+	soft404ProbesMtx.Unlock()
+	probe.once.Do(func() {
+		probe.fingerprint = fetchNotFoundFingerprint(pageUrl)
+	})
+	return probe.fingerprint
+}
+
+func matchPattern(re *regexp.Regexp, text string) bool {
+	return re != nil && re.MatchString(text)
+}
+
+func DetectSoft404(pageUrl nurl.URL, doc *goquery.Document, settings ScanSettings) (bool, string) {
+	fingerprint := NewPageFingerprint(doc, pageUrl)
+	if matchPattern(settings.soft404Title, fingerprint.Title) {
+		return true, fmt.Sprintf("title %q matches soft 404 pattern", fingerprint.Title)
+	}
+	if matchPattern(settings.soft404Text, pageText(doc)) {
+		return true, "text matches soft 404 pattern"
+	}
+	notFound := hostNotFoundFingerprint(pageUrl)
+	if notFound == nil {
+		return false, ""
+	}
+	similarity := fingerprint.Similarity(notFound)
+	if (fingerprint.Title != "" && fingerprint.Title == notFound.Title && similarity >= soft404_title_similar) ||
+		similarity >= soft404_content_similar {
+		return true, fmt.Sprintf("page is %.0f%% similar to the error page of the host", similarity*100)
+	}
+	return false, ""
+}
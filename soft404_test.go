@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const soft404HomePage = `<html><head><title>Shop</title></head><body><p>Welcome to the shop, see our catalog.</p></body></html>`
+
+func TestDetectSoft404IgnoresRedirectedProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.Write([]byte(soft404HomePage))
+	}))
+	defer server.Close()
+	resetSoft404Probes()
+	defer resetSoft404Probes()
+
+	pageUrl, err := nurl.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(soft404HomePage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if soft, reason := DetectSoft404(*pageUrl, doc, NewScanSettings()); soft {
+		t.Errorf("home page taken for soft 404: %s", reason)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	RULE_SD_JSON     = "structured-data-json"
+	RULE_SD_REQUIRED = "structured-data-missing-property"
+)
+
+// Every inner list is a group of alternatives, at least one of which must be present.
+var structuredRequirements = map[string][][]string{
+	"Product":        {{"name"}, {"offers", "review", "aggregateRating"}},
+	"Article":        {{"headline"}, {"author"}, {"datePublished"}},
+	"NewsArticle":    {{"headline"}, {"author"}, {"datePublished"}},
+	"BlogPosting":    {{"headline"}, {"author"}, {"datePublished"}},
+	"BreadcrumbList": {{"itemListElement"}},
+	"Organization":   {{"name"}, {"url"}},
+}
+
+type structuredItem struct {
+	source     string
+	types      []string
+	properties map[string]bool
+	children   []interface{}
+}
+
+func hasValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return strings.TrimSpace(v) != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+func jsonLdTypes(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{path.Base(v)}
+	case []interface{}:
+		types := make([]string, 0, len(v))
+		for _, t := range v {
+			if name, ok := t.(string); ok {
+				types = append(types, path.Base(name))
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func collectJsonLd(value interface{}, items *[]structuredItem) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, element := range v {
+			collectJsonLd(element, items)
+		}
+	case map[string]interface{}:
+		if graph, ok := v["@graph"]; ok {
+			collectJsonLd(graph, items)
+		}
+		types := jsonLdTypes(v["@type"])
+		if len(types) > 0 {
+			item := structuredItem{source: "JSON-LD", types: types, properties: make(map[string]bool)}
+			for key, property := range v {
+				if hasValue(property) {
+					item.properties[key] = true
+				}
+			}
+			if list, ok := v["itemListElement"].([]interface{}); ok {
+				item.children = list
+			}
+			*items = append(*items, item)
+		}
+		for key, property := range v {
+			if key != "@graph" && key != "@type" && key != "@context" {
+				if _, ok := property.(map[string]interface{}); ok {
+					collectJsonLd(property, items)
+				}
+			}
+		}
+	}
+}
+
+func collectMicrodata(doc *goquery.Document, items *[]structuredItem) {
+	doc.Find("[itemscope][itemtype]").Each(func(i int, scope *goquery.Selection) {
+		itemtype, _ := scope.Attr("itemtype")
+		item := structuredItem{source: "microdata", properties: make(map[string]bool)}
+		for _, t := range strings.Fields(itemtype) {
+			item.types = append(item.types, path.Base(t))
+		}
+		scope.Find("[itemprop]").Each(func(j int, prop *goquery.Selection) {
+			if !prop.Parent().Closest("[itemscope]").IsSelection(scope) {
+				return
+			}
+			value, _ := prop.Attr("content")
+			if value == "" {
+				value, _ = prop.Attr("href")
+			}
+			if value == "" {
+				value = strings.TrimSpace(prop.Text())
+			}
+			if value == "" && prop.Is("[itemscope]") {
+				value = "item"
+			}
+			if value == "" {
+				return
+			}
+			for _, name := range strings.Fields(prop.AttrOr("itemprop", "")) {
+				item.properties[name] = true
+			}
+		})
+		*items = append(*items, item)
+	})
+}
+
+func checkRequirements(item structuredItem, pageUrl string) []Finding {
+	findings := make([]Finding, 0)
+	for _, t := range item.types {
+		for _, group := range structuredRequirements[t] {
+			found := false
+			for _, name := range group {
+				found = found || item.properties[name]
+			}
+			if !found {
+				findings = append(findings, Finding{RULE_SD_REQUIRED, SEVERITY_WARNING, pageUrl,
+					fmt.Sprintf("%s %s has no %s", item.source, t, strings.Join(group, " or "))})
+			}
+		}
+		if t != "BreadcrumbList" {
+			continue
+		}
+		for i, child := range item.children {
+			element, ok := child.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if !hasValue(element["position"]) || (!hasValue(element["name"]) && !hasValue(element["item"])) {
+				findings = append(findings, Finding{RULE_SD_REQUIRED, SEVERITY_WARNING, pageUrl,
+					fmt.Sprintf("%s BreadcrumbList element %d needs position and name or item", item.source, i+1)})
+			}
+		}
+	}
+	return findings
+}
+
+func AuditStructuredData(doc *goquery.Document, pageUrl string) ([]string, []Finding) {
+	findings := make([]Finding, 0)
+	items := make([]structuredItem, 0)
+	blocks := 0
+	doc.Find("script[type]").Each(func(i int, script *goquery.Selection) {
+		scriptType, _ := script.Attr("type")
+		if !strings.EqualFold(strings.TrimSpace(scriptType), "application/ld+json") {
+			return
+		}
+		blocks++
+		var value interface{}
+		if err := json.Unmarshal([]byte(script.Text()), &value); err != nil {
+			findings = append(findings, Finding{RULE_SD_JSON, SEVERITY_ERROR, pageUrl,
+				fmt.Sprintf("JSON-LD block %d is not valid JSON: %s", blocks, err)})
+			return
+		}
+		collectJsonLd(value, &items)
+	})
+	collectMicrodata(doc, &items)
+
+	found := make(map[string]bool)
+	types := make([]string, 0)
+	for _, item := range items {
+		findings = append(findings, checkRequirements(item, pageUrl)...)
+		for _, t := range item.types {
+			if !found[t] {
+				found[t] = true
+				types = append(types, t)
+			}
+		}
+	}
+	sort.Strings(types)
+	return types, findings
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+type RequestTiming struct {
+	Dns       time.Duration
+	Connect   time.Duration
+	Tls       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+	start     time.Time
+}
+
+func (rt RequestTiming) String() string {
+	return fmt.Sprintf("DNS %s, connect %s, TLS %s, first byte %s, total %s",
+		rt.Dns.Round(time.Millisecond), rt.Connect.Round(time.Millisecond), rt.Tls.Round(time.Millisecond),
+		rt.FirstByte.Round(time.Millisecond), rt.Total.Round(time.Millisecond))
+}
+
+func (rt *RequestTiming) Finish() {
+	if !rt.start.IsZero() {
+		rt.Total = time.Since(rt.start)
+	}
+}
+
+func durationMs(duration time.Duration) int {
+	return int(duration / time.Millisecond)
+}
+
+type requestTracer struct {
+	timing       *RequestTiming
+	mtx          sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+}
+
+func (rt *requestTracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			rt.mtx.Lock()
+			rt.dnsStart = time.Now()
+			rt.mtx.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			rt.mtx.Lock()
+			rt.timing.Dns += time.Since(rt.dnsStart)
+			rt.mtx.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			rt.mtx.Lock()
+			if rt.connectStart.IsZero() {
+				rt.connectStart = time.Now()
+			}
+			rt.mtx.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			rt.mtx.Lock()
+			if err == nil && !rt.connectStart.IsZero() {
+				rt.timing.Connect += time.Since(rt.connectStart)
+				rt.connectStart = time.Time{}
+			}
+			rt.mtx.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			rt.mtx.Lock()
+			rt.tlsStart = time.Now()
+			rt.mtx.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			rt.mtx.Lock()
+			rt.timing.Tls += time.Since(rt.tlsStart)
+			rt.mtx.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			rt.mtx.Lock()
+			rt.timing.FirstByte = time.Since(rt.timing.start)
+			rt.mtx.Unlock()
+		},
+	}
+}
+
+// doRequest with encoded asks for accept_encoding itself, the body then comes
+// as it was sent and is not decompressed by the transport.
+func doRequest(client *http.Client, method, url string, encoded bool, timing *RequestTiming) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if encoded {
+		req.Header.Set("Accept-Encoding", accept_encoding)
+	}
+	if timing != nil {
+		*timing = RequestTiming{start: time.Now()}
+		tracer := &requestTracer{timing: timing}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), tracer.clientTrace()))
+	}
+	resp, err := client.Do(req)
+	if timing != nil {
+		timing.Finish()
+	}
+	return resp, err
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RULE_TLS_EXPIRING   = "tls-expiring"
+	RULE_TLS_EXPIRED    = "tls-expired"
+	RULE_TLS_HOSTNAME   = "tls-hostname-mismatch"
+	RULE_TLS_SELFSIGNED = "tls-self-signed"
+	RULE_TLS_UNTRUSTED  = "tls-untrusted"
+	RULE_TLS_OLDVERSION = "tls-old-version"
+	RULE_HTTP_ONLY      = "http-only"
+)
+
+type CertificateInfo struct {
+	Subject  string
+	Issuer   string
+	SANs     []string
+	NotAfter time.Time
+}
+
+type HostTlsInfo struct {
+	Host             string
+	Version          string
+	VersionId        uint16
+	Chain            []CertificateInfo
+	SelfSigned       bool
+	HostnameMismatch bool
+	VerifyError      string
+}
+
+type TlsAudit struct {
+	Hosts     map[string]*HostTlsInfo
+	HttpHosts map[string]bool
+	mtx       sync.Mutex
+}
+
+func NewTlsAudit() *TlsAudit {
+	return &TlsAudit{Hosts: make(map[string]*HostTlsInfo), HttpHosts: make(map[string]bool)}
+}
+
+var tlsAudit = NewTlsAudit()
+
+// Reset forgets all hosts, the audit itself is shared with the transport.
+func (ta *TlsAudit) Reset() {
+	ta.Restore(nil)
+}
+
+// Restore replaces the recorded hosts with the ones of a loaded project.
+func (ta *TlsAudit) Restore(saved *TlsAudit) {
+	hosts := make(map[string]*HostTlsInfo)
+	httpHosts := make(map[string]bool)
+	if saved != nil {
+		for host, info := range saved.Hosts {
+			hosts[host] = info
+		}
+		for host := range saved.HttpHosts {
+			httpHosts[host] = true
+		}
+	}
+	ta.mtx.Lock()
+	ta.Hosts = hosts
+	ta.HttpHosts = httpHosts
+	ta.mtx.Unlock()
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionSSL30:
+		return "SSL 3.0"
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04X", version)
+	}
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return cert.Subject.String() == cert.Issuer.String() && cert.CheckSignatureFrom(cert) == nil
+}
+
+func InspectTlsState(host string, state *tls.ConnectionState, roots *x509.CertPool) *HostTlsInfo {
+	info := &HostTlsInfo{Host: host, VersionId: state.Version, Version: tlsVersionName(state.Version)}
+	if len(state.PeerCertificates) == 0 {
+		return info
+	}
+	for _, cert := range state.PeerCertificates {
+		info.Chain = append(info.Chain, CertificateInfo{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			SANs:     cert.DNSNames,
+			NotAfter: cert.NotAfter,
+		})
+	}
+	leaf := state.PeerCertificates[0]
+	last := state.PeerCertificates[len(state.PeerCertificates)-1]
+	info.SelfSigned = isSelfSigned(leaf) || (len(state.PeerCertificates) > 1 && isSelfSigned(last) && !isTrustedRoot(last, roots))
+	info.HostnameMismatch = leaf.VerifyHostname(host) != nil
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, Intermediates: intermediates})
+	if err != nil {
+		info.VerifyError = err.Error()
+	}
+	return info
+}
+
+func isTrustedRoot(cert *x509.Certificate, roots *x509.CertPool) bool {
+	_, err := cert.Verify(x509.VerifyOptions{Roots: roots})
+	return err == nil
+}
+
+func (ta *TlsAudit) Record(resp *http.Response) {
+	if resp == nil || resp.Request == nil || resp.Request.URL == nil {
+		return
+	}
+	host := resp.Request.URL.Hostname()
+	switch resp.Request.URL.Scheme {
+	case "http":
+		ta.mtx.Lock()
+		ta.HttpHosts[host] = true
+		ta.mtx.Unlock()
+	case "https":
+		if resp.TLS == nil {
+			return
+		}
+		sharedTransportMtx.Lock()
+		roots := sharedRootCAs
+		sharedTransportMtx.Unlock()
+		ta.RecordState(host, resp.TLS, roots)
+	}
+}
+
+// RecordState inspects the certificates of the first handshake with host. It is
+// also called from the transport before verification, so failed handshakes are
+// recorded too.
+func (ta *TlsAudit) RecordState(host string, state *tls.ConnectionState, roots *x509.CertPool) {
+	if host == "" || state == nil {
+		return
+	}
+	ta.mtx.Lock()
+	_, known := ta.Hosts[host]
+	ta.mtx.Unlock()
+	if known {
+		return
+	}
+	info := InspectTlsState(host, state, roots)
+	ta.mtx.Lock()
+	if _, known := ta.Hosts[host]; !known {
+		ta.Hosts[host] = info
+	}
+	ta.mtx.Unlock()
+}
+
+func (ta *TlsAudit) Findings(expiryDays int, now time.Time) []Finding {
+	ta.mtx.Lock()
+	defer ta.mtx.Unlock()
+
+	findings := make([]Finding, 0)
+	for host, info := range ta.Hosts {
+		if len(info.Chain) > 0 {
+			notAfter := info.Chain[0].NotAfter
+			if now.After(notAfter) {
+				findings = append(findings, Finding{RULE_TLS_EXPIRED, SEVERITY_ERROR, host,
+					fmt.Sprintf("certificate expired at %s", notAfter.Format("2006-01-02"))})
+			} else if notAfter.Sub(now) < time.Duration(expiryDays)*24*time.Hour {
+				findings = append(findings, Finding{RULE_TLS_EXPIRING, SEVERITY_WARNING, host,
+					fmt.Sprintf("certificate expires at %s", notAfter.Format("2006-01-02"))})
+			}
+		}
+		if info.HostnameMismatch {
+			sans := ""
+			if len(info.Chain) > 0 {
+				sans = strings.Join(info.Chain[0].SANs, ", ")
+			}
+			findings = append(findings, Finding{RULE_TLS_HOSTNAME, SEVERITY_ERROR, host,
+				fmt.Sprintf("certificate is not valid for host (SANs: %s)", sans)})
+		}
+		if info.SelfSigned {
+			findings = append(findings, Finding{RULE_TLS_SELFSIGNED, SEVERITY_WARNING, host, "self-signed certificate chain"})
+		} else if info.VerifyError != "" && !info.HostnameMismatch {
+			findings = append(findings, Finding{RULE_TLS_UNTRUSTED, SEVERITY_ERROR, host, info.VerifyError})
+		}
+		if info.VersionId < tls.VersionTLS12 {
+			findings = append(findings, Finding{RULE_TLS_OLDVERSION, SEVERITY_WARNING, host,
+				fmt.Sprintf("negotiated %s", info.Version)})
+		}
+	}
+	for host := range ta.HttpHosts {
+		if _, ok := ta.Hosts[host]; !ok {
+			findings = append(findings, Finding{RULE_HTTP_ONLY, SEVERITY_WARNING, host, "host was only reached over plain HTTP"})
+		}
+	}
+	sortFindings(findings)
+	return findings
+}
+
+func (ta *TlsAudit) SortedHosts() []*HostTlsInfo {
+	ta.mtx.Lock()
+	defer ta.mtx.Unlock()
+
+	hosts := make([]*HostTlsInfo, 0, len(ta.Hosts))
+	for _, info := range ta.Hosts {
+		hosts = append(hosts, info)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
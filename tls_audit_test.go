@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func hasFinding(findings []Finding, rule, target string) bool {
+	for _, finding := range findings {
+		if finding.Rule == rule && finding.Url == target {
+			return true
+		}
+	}
+	return false
+}
+
+func TestInspectTlsState(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	info := InspectTlsState("127.0.0.1", resp.TLS, x509.NewCertPool())
+	if !info.SelfSigned || info.HostnameMismatch || info.VerifyError == "" {
+		t.Errorf("untrusted self-signed certificate: got %+v", info)
+	}
+	if len(info.Chain) != 1 || info.Version == "" {
+		t.Errorf("chain and version: got %+v", info)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	if info := InspectTlsState("example.com", resp.TLS, roots); info.VerifyError != "" || info.HostnameMismatch {
+		t.Errorf("trusted certificate: got %+v", info)
+	}
+	if info := InspectTlsState("wrong.test", resp.TLS, roots); !info.HostnameMismatch {
+		t.Errorf("wrong host: got %+v", info)
+	}
+}
+
+// auditGet requests host on the test server, names are mapped to the address
+// of the server with a host override.
+func auditGet(t *testing.T, network NetworkSettings, server *httptest.Server, host string) (*TlsAudit, error) {
+	serverUrl, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.ParseIP(host) == nil {
+		network.HostOverrides = []string{host + ":" + serverUrl.Port() + ":" + serverUrl.Hostname()}
+	}
+
+	tlsAudit.Reset()
+	transport, err := newTransport(network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport}).Get("https://" + net.JoinHostPort(host, serverUrl.Port()) + "/")
+	if err == nil {
+		resp.Body.Close()
+	}
+	return tlsAudit, err
+}
+
+// newNamedTLSServer serves a certificate that is valid for example.com only,
+// the returned CA file trusts it.
+func newNamedTLSServer(t *testing.T) (*httptest.Server, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		DNSNames:              []string{"example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	return server, caFile
+}
+
+func TestTlsAuditFailedHandshake(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	audit, err := auditGet(t, NetworkSettings{}, server, "example.com")
+	if err == nil {
+		t.Errorf("handshake with untrusted certificate succeeded")
+	}
+	if _, ok := audit.Hosts["example.com"]; !ok {
+		t.Fatalf("failed handshake not recorded: %v", audit.Hosts)
+	}
+	findings := audit.Findings(30, time.Now())
+	if !hasFinding(findings, RULE_TLS_SELFSIGNED, "example.com") {
+		t.Errorf("self-signed finding missing: %v", findings)
+	}
+	if hasFinding(findings, RULE_TLS_EXPIRED, "example.com") {
+		t.Errorf("unexpected expired finding: %v", findings)
+	}
+
+	expired := server.Certificate().NotAfter.Add(24 * time.Hour)
+	if findings := audit.Findings(30, expired); !hasFinding(findings, RULE_TLS_EXPIRED, "example.com") {
+		t.Errorf("expired finding missing: %v", findings)
+	}
+	soon := server.Certificate().NotAfter.Add(-24 * time.Hour)
+	if findings := audit.Findings(30, soon); !hasFinding(findings, RULE_TLS_EXPIRING, "example.com") {
+		t.Errorf("expiring finding missing: %v", findings)
+	}
+}
+
+func TestTlsAuditHostnameMismatch(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	audit, err := auditGet(t, NetworkSettings{}, server, "wrong.test")
+	if err == nil {
+		t.Errorf("handshake with untrusted certificate succeeded")
+	}
+	findings := audit.Findings(30, time.Now())
+	if !hasFinding(findings, RULE_TLS_HOSTNAME, "wrong.test") {
+		t.Errorf("hostname mismatch finding missing: %v", findings)
+	}
+}
+
+func TestTlsAuditIgnoreErrors(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	audit, err := auditGet(t, NetworkSettings{IgnoreTlsErrors: true}, server, "example.com")
+	if err != nil {
+		t.Errorf("ignored TLS errors: %v", err)
+	}
+	if findings := audit.Findings(30, time.Now()); !hasFinding(findings, RULE_TLS_SELFSIGNED, "example.com") {
+		t.Errorf("self-signed finding missing: %v", findings)
+	}
+}
+
+func TestTlsAuditIpHostMismatch(t *testing.T) {
+	server, caFile := newNamedTLSServer(t)
+	defer server.Close()
+	network := NetworkSettings{CaFiles: []string{caFile}}
+
+	audit, err := auditGet(t, network, server, "127.0.0.1")
+	if err == nil {
+		t.Errorf("handshake with certificate for another host succeeded")
+	}
+	findings := audit.Findings(30, time.Now())
+	if !hasFinding(findings, RULE_TLS_HOSTNAME, "127.0.0.1") {
+		t.Errorf("hostname mismatch finding missing: %v", findings)
+	}
+	if _, ok := audit.Hosts[""]; ok {
+		t.Errorf("state recorded without host: %v", audit.Hosts)
+	}
+
+	audit, err = auditGet(t, network, server, "example.com")
+	if err != nil {
+		t.Errorf("trusted certificate: %v", err)
+	}
+	findings = audit.Findings(30, time.Now())
+	if hasFinding(findings, RULE_TLS_HOSTNAME, "example.com") || hasFinding(findings, RULE_TLS_UNTRUSTED, "example.com") {
+		t.Errorf("trusted certificate: %v", findings)
+	}
+}
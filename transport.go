@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	nurl "net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const max_redirects = 10
+
+var (
+	errTooManyRedirects = fmt.Errorf("stopped after %d redirects", max_redirects)
+)
+
+var (
+	sharedTransport    *http.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	sharedTransportMtx sync.Mutex
+	sharedRootCAs      *x509.CertPool
+)
+
+func init() {
+	if transport, err := newTransport(NetworkSettings{}); err == nil {
+		setSharedTransport(transport)
+	}
+}
+
+func setSharedTransport(transport *http.Transport) {
+	sharedTransportMtx.Lock()
+	old := sharedTransport
+	sharedTransport = transport
+	if transport.TLSClientConfig != nil {
+		sharedRootCAs = transport.TLSClientConfig.RootCAs
+	}
+	sharedTransportMtx.Unlock()
+	old.CloseIdleConnections()
+}
+
+func getSharedTransport() *http.Transport {
+	sharedTransportMtx.Lock()
+	defer sharedTransportMtx.Unlock()
+	return sharedTransport
+}
+
+func loadRootCAs(caFiles []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, caFile := range caFiles {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file %s: %w", caFile, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	return pool, nil
+}
+
+func matchNoProxy(host string, noProxy []string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, rule := range noProxy {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		if rule == "" {
+			continue
+		}
+		if rule == "*" || rule == host {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(rule); err == nil {
+			if ip := net.ParseIP(host); ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if strings.HasSuffix(host, "."+strings.TrimPrefix(rule, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+func proxyFunc(network NetworkSettings) (func(*http.Request) (*nurl.URL, error), error) {
+	if network.ProxyUrl == "" {
+		return nil, nil
+	}
+	proxyUrl, err := nurl.Parse(network.ProxyUrl)
+	if err != nil {
+		return nil, fmt.Errorf("proxy url: %w", err)
+	}
+	switch proxyUrl.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyUrl.Scheme)
+	}
+	noProxy := network.NoProxy
+	return func(r *http.Request) (*nurl.URL, error) {
+		if matchNoProxy(r.URL.Host, noProxy) {
+			return nil, nil
+		}
+		return proxyUrl, nil
+	}, nil
+}
+
+func parseHostOverrides(overrides []string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, override := range overrides {
+		parts := strings.SplitN(override, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("host override %q must look like host:port:address", override)
+		}
+		address := strings.Trim(parts[2], "[]")
+		if net.ParseIP(address) == nil {
+			return nil, fmt.Errorf("host override %q has invalid address", override)
+		}
+		result[net.JoinHostPort(strings.ToLower(parts[0]), parts[1])] = address
+	}
+	return result, nil
+}
+
+func overrideDial(overrides map[string]string) func(context.Context, string, string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err == nil {
+			host = strings.ToLower(host)
+			if address, ok := overrides[net.JoinHostPort(host, port)]; ok {
+				addr = net.JoinHostPort(address, port)
+			} else if address, ok := overrides[net.JoinHostPort(host, "*")]; ok {
+				addr = net.JoinHostPort(address, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+func newTransport(network NetworkSettings) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	overrides, err := parseHostOverrides(network.HostOverrides)
+	if err != nil {
+		return nil, err
+	}
+	if len(overrides) > 0 {
+		transport.DialContext = overrideDial(overrides)
+	}
+
+	proxy, err := proxyFunc(network)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	rootCAs, err := loadRootCAs(network.CaFiles)
+	if err != nil {
+		return nil, err
+	}
+	// Connections through a proxy use this config as it is and are only
+	// recorded after a successful handshake, direct ones go through dialTls.
+	tlsConfig := &tls.Config{
+		RootCAs:            rootCAs,
+		InsecureSkipVerify: network.IgnoreTlsErrors,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			tlsAudit.RecordState(state.ServerName, &state, rootCAs)
+			return nil
+		},
+	}
+	if network.ClientCertFile != "" || network.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(network.ClientCertFile, network.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	transport.DialTLSContext = dialTls(transport, rootCAs, network.IgnoreTlsErrors)
+	return transport, nil
+}
+
+// dialTls does the handshake itself, so the certificates are verified against
+// the dialed host, IP addresses included, and recorded in the TLS audit even
+// when the verification fails.
+func dialTls(transport *http.Transport, roots *x509.CertPool, ignoreErrors bool) func(context.Context, string, string) (net.Conn, error) {
+	dial := transport.DialContext
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		config := transport.TLSClientConfig.Clone()
+		config.ServerName = host
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = recordAndVerify(host, roots, ignoreErrors)
+
+		trace := httptrace.ContextClientTrace(ctx)
+		if trace != nil && trace.TLSHandshakeStart != nil {
+			trace.TLSHandshakeStart()
+		}
+		tlsConn := tls.Client(conn, config)
+		err = tlsConn.HandshakeContext(ctx)
+		if trace != nil && trace.TLSHandshakeDone != nil {
+			trace.TLSHandshakeDone(tlsConn.ConnectionState(), err)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+func limitRedirects(r *http.Request, via []*http.Request) error {
+	if len(via) >= max_redirects {
+		return errTooManyRedirects
+	}
+	return nil
+}
+
+func noRedirects(r *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+func keepOpaquePath(r *http.Request, via []*http.Request) error {
+	if err := limitRedirects(r, via); err != nil {
+		return err
+	}
+	r.URL.Opaque = r.URL.Path
+	return nil
+}
+
+func newHttpClient(timeout time.Duration, checkRedirect func(*http.Request, []*http.Request) error) *http.Client {
+	return &http.Client{
+		Transport:     getSharedTransport(),
+		Timeout:       timeout,
+		CheckRedirect: checkRedirect,
+	}
+}
+
+func recordAndVerify(host string, roots *x509.CertPool, ignoreErrors bool) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		tlsAudit.RecordState(host, &state, roots)
+		if ignoreErrors {
+			return nil
+		}
+		if len(state.PeerCertificates) == 0 {
+			return fmt.Errorf("tls: server sent no certificates")
+		}
+		return verifyChain(&state, host, roots)
+	}
+}
+
+func verifyConnectionState(state *tls.ConnectionState, host string) error {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	sharedTransportMtx.Lock()
+	roots := sharedRootCAs
+	sharedTransportMtx.Unlock()
+	return verifyChain(state, host, roots)
+}
+
+func verifyChain(state *tls.ConnectionState, host string, roots *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+func isTlsUnverified(resp *http.Response) bool {
+	if resp == nil || resp.TLS == nil || !currentSettings().Network.IgnoreTlsErrors {
+		return false
+	}
+	return verifyConnectionState(resp.TLS, resp.Request.URL.Hostname()) != nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"strings"
 	"sync"
 
 	"github.com/gotk3/gotk3/gdk"
@@ -12,6 +13,12 @@ import (
 const (
 	ONE_COLUMN_IMG = iota
 	ONE_COLUMN_TEXT
+	ONE_COLUMN_TOOLTIP
+	ONE_COLUMN_TTFB
+	ONE_COLUMN_TOTAL
+	ONE_COLUMN_DEPTH
+	ONE_COLUMN_INLINKS
+	ONE_COLUMN_RANK
 )
 
 const (
@@ -19,22 +26,31 @@ const (
 	TWO_COLUMN_IMG_2
 	TWO_COLUMN_SIZE
 	TWO_COLUMN_TEXT
+	TWO_COLUMN_TOOLTIP
+	TWO_COLUMN_TTFB
+	TWO_COLUMN_TOTAL
+	TWO_COLUMN_ELEMENT
+	TWO_COLUMN_ANCHOR
+	TWO_COLUMN_LINE
 )
 
 var (
-	clear_pixbuf       *gdk.Pixbuf
-	question_pixbuf    *gdk.Pixbuf
-	check_pixbuf       *gdk.Pixbuf
-	remove_pixbuf      *gdk.Pixbuf
-	wait_pixbuf        *gdk.Pixbuf
-	teapot_pixbuf      *gdk.Pixbuf
-	not_found_pixbuf   *gdk.Pixbuf
-	robot_pixbuf       *gdk.Pixbuf
-	not_allowed_pixbuf *gdk.Pixbuf
-	tmr_pixbuf         *gdk.Pixbuf
-	ise_pixbuf         *gdk.Pixbuf
-	href_pixbuf        *gdk.Pixbuf
-	src_pixbuf         *gdk.Pixbuf
+	clear_pixbuf          *gdk.Pixbuf
+	question_pixbuf       *gdk.Pixbuf
+	check_pixbuf          *gdk.Pixbuf
+	remove_pixbuf         *gdk.Pixbuf
+	wait_pixbuf           *gdk.Pixbuf
+	teapot_pixbuf         *gdk.Pixbuf
+	not_found_pixbuf      *gdk.Pixbuf
+	robot_pixbuf          *gdk.Pixbuf
+	not_allowed_pixbuf    *gdk.Pixbuf
+	tmr_pixbuf            *gdk.Pixbuf
+	ise_pixbuf            *gdk.Pixbuf
+	soft_not_found_pixbuf *gdk.Pixbuf
+	href_pixbuf           *gdk.Pixbuf
+	src_pixbuf            *gdk.Pixbuf
+	css_pixbuf            *gdk.Pixbuf
+	meta_pixbuf           *gdk.Pixbuf
 )
 
 var pixbufMtx sync.Mutex
@@ -73,14 +89,27 @@ func createTextColumn(title string, id int) *gtk.TreeViewColumn {
 	return column
 }
 
+func createSortedTextColumn(title string, id int) *gtk.TreeViewColumn {
+	column := createTextColumn(title, id)
+	column.SetSortColumnID(id)
+	return column
+}
+
 func setupTreeViewLikeTree(treeView *gtk.TreeView) *gtk.TreeStore {
 	treeView.AppendColumn(createImageColumn("Status", ONE_COLUMN_IMG))
 	treeView.AppendColumn(createTextColumn("Url", ONE_COLUMN_TEXT))
-	treeStore, err := gtk.TreeStoreNew(gdk.PixbufGetType(), glib.TYPE_STRING)
+	treeView.AppendColumn(createSortedTextColumn("TTFB, ms", ONE_COLUMN_TTFB))
+	treeView.AppendColumn(createSortedTextColumn("Total, ms", ONE_COLUMN_TOTAL))
+	treeView.AppendColumn(createSortedTextColumn("Depth", ONE_COLUMN_DEPTH))
+	treeView.AppendColumn(createSortedTextColumn("Inlinks", ONE_COLUMN_INLINKS))
+	treeView.AppendColumn(createSortedTextColumn("PageRank", ONE_COLUMN_RANK))
+	treeStore, err := gtk.TreeStoreNew(gdk.PixbufGetType(), glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_INT, glib.TYPE_INT,
+		glib.TYPE_INT, glib.TYPE_INT, glib.TYPE_INT)
 	if err != nil {
 		log.Fatal("Unable to create list store:", err)
 	}
 	treeView.SetModel(treeStore)
+	treeView.SetTooltipColumn(ONE_COLUMN_TOOLTIP)
 	return treeStore
 }
 
@@ -88,12 +117,19 @@ func setupTreeViewLikeList(treeView *gtk.TreeView) *gtk.ListStore {
 	treeView.AppendColumn(createImageColumn("Intent", TWO_COLUMN_IMG))
 	treeView.AppendColumn(createImageColumn("Status", TWO_COLUMN_IMG_2))
 	treeView.AppendColumn(createTextColumn("Size", TWO_COLUMN_SIZE))
+	treeView.AppendColumn(createSortedTextColumn("TTFB, ms", TWO_COLUMN_TTFB))
+	treeView.AppendColumn(createSortedTextColumn("Total, ms", TWO_COLUMN_TOTAL))
 	treeView.AppendColumn(createTextColumn("Url", TWO_COLUMN_TEXT))
-	treeStore, err := gtk.ListStoreNew(gdk.PixbufGetType(), gdk.PixbufGetType(), glib.TYPE_STRING, glib.TYPE_STRING)
+	treeView.AppendColumn(createSortedTextColumn("Element", TWO_COLUMN_ELEMENT))
+	treeView.AppendColumn(createSortedTextColumn("Text", TWO_COLUMN_ANCHOR))
+	treeView.AppendColumn(createSortedTextColumn("Line", TWO_COLUMN_LINE))
+	treeStore, err := gtk.ListStoreNew(gdk.PixbufGetType(), gdk.PixbufGetType(), glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING,
+		glib.TYPE_INT, glib.TYPE_INT, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_INT)
 	if err != nil {
 		log.Fatal("Unable to create list store:", err)
 	}
 	treeView.SetModel(treeStore)
+	treeView.SetTooltipColumn(TWO_COLUMN_TOOLTIP)
 	return treeStore
 }
 
@@ -121,6 +157,8 @@ func getPixbufByStatus(status int) *gdk.Pixbuf {
 		return not_allowed_pixbuf
 	case STATUS_ISE:
 		return ise_pixbuf
+	case STATUS_SOFT404:
+		return soft_not_found_pixbuf
 	default:
 		return remove_pixbuf
 	}
@@ -132,17 +170,80 @@ func getPixbufByIntent(intent int) *gdk.Pixbuf {
 		return href_pixbuf
 	case INTENT_SRC:
 		return src_pixbuf
+	case INTENT_CSS:
+		return css_pixbuf
+	case INTENT_META:
+		return meta_pixbuf
 	default:
 		return clear_pixbuf
 	}
 }
 
+func tlsMark(unverified bool) string {
+	if unverified {
+		return "[TLS unverified] "
+	}
+	return ""
+}
+
+func pageTooltip(uts *UrlTreeStruct) string {
+	lines := make([]string, 0)
+	if uts.Trap != "" {
+		lines = append(lines, "Suspected crawl trap, not expanded: "+uts.Trap)
+	}
+	if uts.Failure != FAILURE_NONE {
+		lines = append(lines, failureTooltip(uts.Failure, uts.FailureMessage))
+	}
+	if uts.Timing.Total > 0 {
+		lines = append(lines, uts.Timing.String())
+	}
+	if uts.PageSize > 0 {
+		lines = append(lines, "Page weight: "+uts.PageWeight().String())
+	}
+	if uts.Sitemap != SITEMAP_UNCHECKED {
+		lines = append(lines, "Sitemap: "+sitemapName(uts.Sitemap))
+	}
+	if uts.Metrics != (LinkMetrics{}) {
+		lines = append(lines, uts.Metrics.String())
+	}
+	if uts.Duplicate != DUPLICATE_NONE {
+		lines = append(lines, "Content: "+duplicateName(uts.Duplicate))
+	}
+	return strings.Join(lines, "\n")
+}
+
 func applyTree(store *gtk.TreeStore, root *UrlTreeStruct) {
 	store.Clear()
-	applyTreeBranch(store, nil, root)
+	visible := visibleBranches(root, urlTreeFilter.GetActiveID(), currentSettings().MaxClickDepth)
+	applyTreeBranch(store, nil, root, visible)
+}
+
+// visibleBranches returns the pages matching the tree view together with their
+// ancestors, or nil when every page is shown.
+func visibleBranches(root *UrlTreeStruct, view string, maxDepth int) map[*UrlTreeStruct]bool {
+	if view == "" || view == TREE_VIEW_ALL {
+		return nil
+	}
+	visible := make(map[*UrlTreeStruct]bool)
+	root.Walk(func(uts *UrlTreeStruct) {
+		if !treeViewMatches(view, uts, maxDepth) {
+			return
+		}
+		for node := uts; node != nil && !visible[node]; node = node.Parent {
+			visible[node] = true
+		}
+	})
+	visible[root] = true
+	return visible
 }
 
-func applyTreeBranch(store *gtk.TreeStore, parentIter *gtk.TreeIter, child *UrlTreeStruct) {
+func applyTreeBranch(store *gtk.TreeStore, parentIter *gtk.TreeIter, child *UrlTreeStruct, visible map[*UrlTreeStruct]bool) {
+	if visible != nil && !visible[child] {
+		child.Walk(func(uts *UrlTreeStruct) {
+			uts.TreeIter = nil
+		})
+		return
+	}
 	iter := store.Append(parentIter)
 	child.TreeIter = iter
 	selected_pixbuf := getPixbufByStatus(child.Status)
@@ -152,26 +253,110 @@ func applyTreeBranch(store *gtk.TreeStore, parentIter *gtk.TreeIter, child *UrlT
 			log.Fatal("Unable config row:", err)
 		}
 	}
-	err := treeStore.SetValue(iter, ONE_COLUMN_TEXT, child.GetUrlAccordingParent())
+	err := treeStore.SetValue(iter, ONE_COLUMN_TEXT, trapMark(child.Trap)+tlsMark(child.TlsUnverified)+sitemapMark(child.Sitemap)+duplicateMark(child.Duplicate)+child.GetUrlAccordingParent())
+	if err != nil {
+		log.Fatal("Unable config row:", err)
+	}
+	err = treeStore.SetValue(iter, ONE_COLUMN_TOOLTIP, pageTooltip(child))
+	if err != nil {
+		log.Fatal("Unable config row:", err)
+	}
+	err = treeStore.SetValue(iter, ONE_COLUMN_TTFB, durationMs(child.Timing.FirstByte))
+	if err != nil {
+		log.Fatal("Unable config row:", err)
+	}
+	err = treeStore.SetValue(iter, ONE_COLUMN_TOTAL, durationMs(child.Timing.Total))
+	if err != nil {
+		log.Fatal("Unable config row:", err)
+	}
+	err = treeStore.SetValue(iter, ONE_COLUMN_DEPTH, child.Metrics.Depth)
+	if err != nil {
+		log.Fatal("Unable config row:", err)
+	}
+	err = treeStore.SetValue(iter, ONE_COLUMN_INLINKS, child.Metrics.LinkingPages)
+	if err != nil {
+		log.Fatal("Unable config row:", err)
+	}
+	err = treeStore.SetValue(iter, ONE_COLUMN_RANK, child.Metrics.Score)
 	if err != nil {
 		log.Fatal("Unable config row:", err)
 	}
 	for _, chld := range child.Childs {
-		applyTreeBranch(store, iter, chld)
+		applyTreeBranch(store, iter, chld, visible)
+	}
+}
+
+func mixedMark(mixed int) string {
+	switch mixed {
+	case MIXED_ACTIVE:
+		return "[mixed active] "
+	case MIXED_PASSIVE:
+		return "[mixed passive] "
+	default:
+		return ""
 	}
 }
 
+func innerUrlTooltip(us UrlStruct) string {
+	lines := make([]string, 0)
+	if context := us.Context.String(); context != "" {
+		lines = append(lines, context)
+	}
+	if us.Failure != FAILURE_NONE {
+		lines = append(lines, failureTooltip(us.Failure, us.FailureMessage))
+	}
+	if us.Timing.Total > 0 {
+		lines = append(lines, us.Timing.String())
+	}
+	if us.MixedContent != MIXED_NONE {
+		lines = append(lines, mixedName(us.MixedContent))
+		if us.HttpsAlternative != "" {
+			lines = append(lines, "HTTPS version: "+us.HttpsAlternative)
+		}
+	}
+	if cache := us.Cache.String(); cache != "" {
+		lines = append(lines, cache)
+	}
+	return strings.Join(lines, "\n")
+}
+
 func applyList(store *gtk.ListStore, list *[]UrlStruct) {
 	store.Clear()
 	for _, us := range *list {
 		intent_pixbuf := getPixbufByIntent(us.Intent)
 		status_pixbuf := getPixbufByStatus(us.Status)
-		store.Set(store.Append(), []int{TWO_COLUMN_IMG, TWO_COLUMN_IMG_2, TWO_COLUMN_SIZE, TWO_COLUMN_TEXT},
-			[]interface{}{intent_pixbuf, status_pixbuf, us.GetShortSizeFormat(), us.Url})
+		store.Set(store.Append(), []int{TWO_COLUMN_IMG, TWO_COLUMN_IMG_2, TWO_COLUMN_SIZE, TWO_COLUMN_TEXT, TWO_COLUMN_TOOLTIP,
+			TWO_COLUMN_TTFB, TWO_COLUMN_TOTAL, TWO_COLUMN_ELEMENT, TWO_COLUMN_ANCHOR, TWO_COLUMN_LINE},
+			[]interface{}{intent_pixbuf, status_pixbuf, us.GetShortSizeFormat(),
+				tlsMark(us.TlsUnverified) + mixedMark(us.MixedContent) + us.Url, innerUrlTooltip(us),
+				durationMs(us.Timing.FirstByte), durationMs(us.Timing.Total),
+				us.Context.Element(), us.Context.Text, us.Context.Line})
+	}
+}
+
+func getPixbufBySeverity(severity int) *gdk.Pixbuf {
+	switch severity {
+	case SEVERITY_ERROR:
+		return remove_pixbuf
+	case SEVERITY_WARNING:
+		return question_pixbuf
+	default:
+		return clear_pixbuf
+	}
+}
+
+func applyFindings(store *gtk.ListStore, findings []Finding) {
+	store.Clear()
+	for _, f := range findings {
+		store.Set(store.Append(), []int{TWO_COLUMN_IMG, TWO_COLUMN_IMG_2, TWO_COLUMN_SIZE, TWO_COLUMN_TEXT, TWO_COLUMN_TOOLTIP},
+			[]interface{}{getPixbufBySeverity(f.Severity), clear_pixbuf, f.Rule, f.Message + ": " + f.Url, f.String()})
 	}
 }
 
 func expandToItem(treeView *gtk.TreeView, store *gtk.TreeStore, node *UrlTreeStruct) {
+	if node.TreeIter == nil {
+		return
+	}
 	path, _ := store.GetPath(node.TreeIter)
 	treeView.ExpandToPath(path)
 	selection, _ := treeView.GetSelection()
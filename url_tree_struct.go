@@ -20,14 +20,26 @@ const (
 const (
 	INTENT_HREF = iota
 	INTENT_SRC
+	INTENT_CSS
+	INTENT_META
 )
 
 type UrlStruct struct {
-	Url        string
-	Status     int
-	LinkType   int
-	Intent     int
-	SourceSize int64
+	Url              string
+	Status           int
+	LinkType         int
+	Intent           int
+	SourceSize       int64
+	TlsUnverified    bool
+	Failure          int
+	FailureMessage   string
+	MixedContent     int
+	HttpsAlternative string
+	Timing           RequestTiming
+	ContentType      string
+	ResourceType     int
+	Cache            CacheInfo
+	Context          LinkContext
 }
 
 func (us UrlStruct) String() string {
@@ -51,42 +63,108 @@ func (us UrlStruct) GetSizeMiB() float64 {
 }
 
 func (us UrlStruct) GetShortSizeFormat() string {
+	return formatSize(us.SourceSize)
+}
+
+func formatSize(sourceSize int64) string {
 	var size string
-	if us.SourceSize == -1 {
+	if sourceSize == -1 {
 		size = "Unknown"
 	} else {
-		size = fmt.Sprintf("%.2f MiB", us.GetSizeMiB())
+		size = fmt.Sprintf("%.2f MiB", float64(sourceSize)/1024/1024)
 		if size == "0.00 MiB" {
-			size = fmt.Sprintf("%.2f KiB", us.GetSizeKiB())
+			size = fmt.Sprintf("%.2f KiB", float64(sourceSize)/1024)
 		}
 		if size == "0.00 KiB" {
-			size = fmt.Sprintf("%.2f B", us.GetSizeB())
+			size = fmt.Sprintf("%.2f B", float64(sourceSize))
 		}
 	}
 	return size
 }
 
 type UrlTreeStruct struct {
-	Url        string
-	Status     int
-	Parent     *UrlTreeStruct
-	Childs     []*UrlTreeStruct
-	childMutex sync.Mutex
-	InnerUrls  []UrlStruct
-	innerMutex sync.Mutex
-	TreeIter   *gtk.TreeIter
+	Url            string
+	Status         int
+	TlsUnverified  bool
+	Failure        int
+	FailureMessage string
+	Timing         RequestTiming
+	PageSize       int64
+	Seo            SeoInfo
+	Sitemap        int
+	LastModified   string
+	Trap           string
+	Content        ContentSignature
+	Duplicate      int
+	Metrics        LinkMetrics
+	OutLinks       []PageLink
+	Parent         *UrlTreeStruct
+	Childs         []*UrlTreeStruct
+	childMutex     sync.Mutex
+	InnerUrls      []UrlStruct
+	innerMutex     sync.Mutex
+	Findings       []Finding
+	findingMutex   sync.Mutex
+	TreeIter       *gtk.TreeIter
 }
 
 func NewUrlTreeStruct(url string) *UrlTreeStruct {
 	return &UrlTreeStruct{Url: url, Status: STATUS_NO_INFO, Childs: make([]*UrlTreeStruct, 0)}
 }
 
+func (uts *UrlTreeStruct) SetFailure(failure int, err error) {
+	uts.Failure = failure
+	uts.FailureMessage = ""
+	if err != nil {
+		uts.FailureMessage = err.Error()
+	}
+}
+
 func (uts *UrlTreeStruct) AppendInnerUrl(newInnerUrl *UrlStruct) {
 	uts.innerMutex.Lock()
 	uts.InnerUrls = append(uts.InnerUrls, *newInnerUrl)
 	uts.innerMutex.Unlock()
 }
 
+func (uts *UrlTreeStruct) AppendFinding(finding Finding) {
+	uts.findingMutex.Lock()
+	uts.Findings = append(uts.Findings, finding)
+	uts.findingMutex.Unlock()
+}
+
+func (uts *UrlTreeStruct) RemoveFindings(rules []string) {
+	removed := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		removed[rule] = true
+	}
+	uts.findingMutex.Lock()
+	kept := make([]Finding, 0, len(uts.Findings))
+	for _, finding := range uts.Findings {
+		if !removed[finding.Rule] {
+			kept = append(kept, finding)
+		}
+	}
+	uts.Findings = kept
+	uts.findingMutex.Unlock()
+}
+
+// ResetAudit clears what the previous check of the page found, so a page that
+// no longer answers 200 keeps no stale results.
+func (uts *UrlTreeStruct) ResetAudit() {
+	uts.TlsUnverified = false
+	uts.PageSize = 0
+	uts.LastModified = ""
+	uts.Seo = SeoInfo{}
+	uts.Content = ContentSignature{}
+	uts.Duplicate = DUPLICATE_NONE
+	uts.innerMutex.Lock()
+	uts.InnerUrls = make([]UrlStruct, 0)
+	uts.innerMutex.Unlock()
+	uts.findingMutex.Lock()
+	uts.Findings = make([]Finding, 0)
+	uts.findingMutex.Unlock()
+}
+
 func (p *UrlTreeStruct) AppendChild(newChild *UrlTreeStruct) bool {
 	if newChild == nil || newChild == p || p.Parent == newChild {
 		return false
@@ -124,7 +202,7 @@ func (r *UrlTreeStruct) FindByUrl(url string) *UrlTreeStruct {
 }
 
 func (r *UrlTreeStruct) FindByTreeIter(treeIter *gtk.TreeIter) *UrlTreeStruct {
-	if r.TreeIter.GtkTreeIter == treeIter.GtkTreeIter {
+	if r.TreeIter != nil && r.TreeIter.GtkTreeIter == treeIter.GtkTreeIter {
 		return r
 	}
 	for _, uts := range r.Childs {
@@ -158,6 +236,13 @@ func (r *UrlTreeStruct) AppendAccordingUrl(newChild *UrlTreeStruct) bool {
 	}
 }
 
+func (uts *UrlTreeStruct) Walk(visit func(*UrlTreeStruct)) {
+	visit(uts)
+	for _, child := range uts.Childs {
+		child.Walk(visit)
+	}
+}
+
 func (uts *UrlTreeStruct) Deep() int {
 	if len(uts.Childs) == 0 {
 		return 1
@@ -173,13 +258,71 @@ func (uts *UrlTreeStruct) Deep() int {
 }
 
 type UrlTreeStructCard struct {
-	Url       string
-	Status    int
-	InnerUrls []UrlStruct
+	Url            string
+	Status         int
+	InnerUrls      []UrlStruct
+	TlsUnverified  bool
+	Failure        int
+	FailureMessage string
+	Findings       []Finding
+	Timing         RequestTiming
+	PageSize       int64
+	Seo            SeoInfo
+	Sitemap        int
+	LastModified   string
+	Trap           string
+	Content        ContentSignature
+	Duplicate      int
+	Metrics        LinkMetrics
+	OutLinks       []PageLink
+}
+
+func (uts *UrlTreeStruct) Card() UrlTreeStructCard {
+	return UrlTreeStructCard{
+		Url:            uts.Url,
+		Status:         uts.Status,
+		InnerUrls:      uts.InnerUrls,
+		TlsUnverified:  uts.TlsUnverified,
+		Failure:        uts.Failure,
+		FailureMessage: uts.FailureMessage,
+		Findings:       uts.Findings,
+		Timing:         uts.Timing,
+		PageSize:       uts.PageSize,
+		Seo:            uts.Seo,
+		Sitemap:        uts.Sitemap,
+		LastModified:   uts.LastModified,
+		Trap:           uts.Trap,
+		Content:        uts.Content,
+		Duplicate:      uts.Duplicate,
+		Metrics:        uts.Metrics,
+		OutLinks:       uts.OutLinks,
+	}
+}
+
+func NewUrlTreeStructFromCard(utsc UrlTreeStructCard) *UrlTreeStruct {
+	return &UrlTreeStruct{
+		Url:            utsc.Url,
+		Status:         utsc.Status,
+		InnerUrls:      utsc.InnerUrls,
+		TlsUnverified:  utsc.TlsUnverified,
+		Failure:        utsc.Failure,
+		FailureMessage: utsc.FailureMessage,
+		Findings:       utsc.Findings,
+		Timing:         utsc.Timing,
+		PageSize:       utsc.PageSize,
+		Seo:            utsc.Seo,
+		Sitemap:        utsc.Sitemap,
+		LastModified:   utsc.LastModified,
+		Trap:           utsc.Trap,
+		Content:        utsc.Content,
+		Duplicate:      utsc.Duplicate,
+		Metrics:        utsc.Metrics,
+		OutLinks:       utsc.OutLinks,
+	}
 }
 
 func (uts *UrlTreeStruct) CopyAsList(card *[]UrlTreeStructCard) {
-	*card = append(*card, UrlTreeStructCard{uts.Url, uts.Status, uts.InnerUrls})
+	*card = append(*card, uts.Card())
 	if len(uts.Childs) == 0 {
 		return
 	}
@@ -197,11 +340,9 @@ func RestoreFromList(card *[]UrlTreeStructCard) *UrlTreeStruct {
 		return (*card)[i].Url < (*card)[j].Url
 	})
 
-	root_utsc := (*card)[0]
-	urlTree := &UrlTreeStruct{Url: root_utsc.Url, Status: root_utsc.Status, InnerUrls: root_utsc.InnerUrls}
+	urlTree := NewUrlTreeStructFromCard((*card)[0])
 	for i := 1; i < len(*card); i++ {
-		utsc := (*card)[i]
-		nts := &UrlTreeStruct{Url: utsc.Url, Status: utsc.Status, InnerUrls: utsc.InnerUrls}
+		nts := NewUrlTreeStructFromCard((*card)[i])
 		urlTree.AppendAccordingUrl(nts)
 	}
 	return urlTree